@@ -10,17 +10,44 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mcellteam/nutmeg/src/cache"
 	"github.com/mcellteam/nutmeg/src/engine"
+	"github.com/mcellteam/nutmeg/src/file"
 	"github.com/mcellteam/nutmeg/src/misc"
+	"github.com/mcellteam/nutmeg/src/report"
+	"github.com/mcellteam/nutmeg/src/tester"
 	"github.com/mcellteam/nutmeg/src/tomlParser"
 )
 
+// name of the state file recording the failed tests of previous runs
+const failedTestsFile = "nutmeg.failed"
+
+// name of the state file recording the runtimes of the tests of previous runs
+const runtimesFile = "nutmeg.runtimes"
+
+// upper limit of randomly picked master seeds
+const maxMasterSeed = 1000000
+
+// default master seed of runs using the simulation cache so that cached
+// output of single seed tests can be reused across runs
+const cacheMasterSeed = 1
+
+// exit codes of nutmeg
+const (
+	exitSuccess       = 0 // all tests passed
+	exitTestFailures  = 1 // one or more tests failed
+	exitBadInvocation = 2 // invalid command line
+	exitInfraError    = 3 // nutmeg failed to run the tests properly
+)
+
 // command line flags
 var listTestsFlag bool
 var listCategoriesFlag bool
@@ -30,6 +57,24 @@ var categorySelection string
 var descriptionSelectionShort string
 var numSimJobs int
 var numTestJobs int
+var junitReport string
+var jsonReport string
+var timeout float64
+var rerunFailed bool
+var reportFixed bool
+var numSlowestTests int
+var analyzeOnly bool
+var noCache bool
+var purgeCache bool
+var masterSeed int64
+var outputDir string
+var keepPolicy string
+var retentionPolicy engine.RetentionPolicy
+var dryRun bool
+var shardSpec string
+var shard engine.Shard
+var shardTimes string
+var mergeFiles bool
 
 // initialize list of available unit tests
 func init() {
@@ -42,6 +87,35 @@ func init() {
 		"show description for selected tests (i, i:j, or 'all')")
 	flag.IntVar(&numSimJobs, "n", 2, "number of concurrent simulation jobs (default: 2)")
 	flag.IntVar(&numTestJobs, "m", 2, "number of concurrent test jobs (default: 2)")
+	flag.StringVar(&junitReport, "junit", "", "write JUnit XML report of test results to file")
+	flag.StringVar(&jsonReport, "json", "", "write test results as JSON Lines to file")
+	flag.Float64Var(&timeout, "timeout", 0,
+		"default timeout in seconds for each MCell run (overrides nutmeg.conf)")
+	flag.BoolVar(&rerunFailed, "failed", false,
+		"only run tests which failed previously (by itself or with -r, -R)")
+	flag.BoolVar(&reportFixed, "fixed", false,
+		"report previously failed tests which now pass")
+	flag.BoolVar(&analyzeOnly, "analyze", false,
+		"rerun the checks on the output of the previous run without running MCell")
+	flag.IntVar(&numSlowestTests, "slowest", 10,
+		"number of slowest tests to report after a run (0 disables the report)")
+	flag.BoolVar(&noCache, "nocache", false,
+		"run all simulations without using the simulation cache")
+	flag.BoolVar(&purgeCache, "purgecache", false, "remove all entries from the simulation cache")
+	flag.Int64Var(&masterSeed, "seed", 0,
+		"master seed from which the seeds of all single seed tests are derived")
+	flag.StringVar(&outputDir, "output", "",
+		"root directory for test output (overrides nutmeg.conf)")
+	flag.BoolVar(&dryRun, "dryrun", false,
+		"print the planned MCell invocations and checks without running anything")
+	flag.StringVar(&keepPolicy, "keep", "",
+		"retention policy for test output: all, failed, or archive (overrides nutmeg.conf)")
+	flag.StringVar(&shardSpec, "shard", "",
+		"only run shard i of n (i/n) of the selected tests")
+	flag.StringVar(&shardTimes, "shardtimes", "",
+		"balance shards using the test runtimes recorded in file")
+	flag.BoolVar(&mergeFiles, "merge", false,
+		"merge the JSON result files given as arguments into one summary")
 
 }
 
@@ -50,20 +124,61 @@ func main() {
 
 	nutmegConf, err := tomlParser.ReadConfig()
 	if err != nil {
-		log.Fatal("Error reading nutmeg.conf: ", err)
+		fatal(exitInfraError, "Error reading nutmeg.conf: ", err)
 	}
 
 	startTime := time.Now()
 
 	testNames, err := gatherTests(nutmegConf.TestDir)
 	if err != nil {
-		log.Fatal("Could not determine list of available test cases")
+		fatal(exitInfraError, "Could not determine list of available test cases")
 	}
 
 	flag.Parse()
 	if (testSelection != "") && (categorySelection != "") {
-		log.Fatal("The r and R flags are mutually exclusive")
+		fatal(exitBadInvocation, "The r and R flags are mutually exclusive")
+	}
+	if timeout > 0 {
+		nutmegConf.Timeout = timeout
+	}
+	if noCache {
+		nutmegConf.CacheDir = ""
+	}
+	if masterSeed != 0 {
+		nutmegConf.Seed = masterSeed
+	}
+	if outputDir != "" {
+		nutmegConf.OutputDir = outputDir
+	}
+	if keepPolicy != "" {
+		nutmegConf.Keep = keepPolicy
+	}
+	if retentionPolicy, err = engine.ParseRetentionPolicy(nutmegConf.Keep); err != nil {
+		fatal(exitBadInvocation, err)
+	}
+	if shardSpec != "" {
+		if shard, err = engine.ParseShard(shardSpec); err != nil {
+			fatal(exitBadInvocation, err)
+		}
+	} else if shardTimes != "" {
+		fatal(exitBadInvocation, "The shardtimes flag requires the shard flag")
+	}
+	if nutmegConf.OutputDir != "" {
+		root, err := filepath.Abs(nutmegConf.OutputDir)
+		if err != nil {
+			fatal(exitBadInvocation, "Invalid output directory: ", err)
+		}
+		testDir, err := filepath.Abs(nutmegConf.TestDir)
+		if err != nil {
+			fatal(exitInfraError, "Invalid test directory: ", err)
+		}
+		nutmegConf.OutputDir = root
+		if err := file.SetOutputRoot(root, testDir); err != nil {
+			fatal(exitBadInvocation, "Invalid output directory: ", err)
+		}
 	}
+
+	exitCode := exitSuccess
 	switch {
 	case listTestsFlag:
 		fmt.Println("Available tests:")
@@ -85,10 +200,27 @@ func main() {
 		for i, t := range testNames {
 			testPaths[i] = filepath.Join(nutmegConf.TestDir, t)
 		}
-		if err := misc.CleanOutput(testPaths); err != nil {
-			log.Fatal(err)
+		lock := acquireLock(nutmegConf)
+		err := misc.CleanOutput(testPaths)
+		lock.Release()
+		if err != nil {
+			fatal(exitInfraError, err)
 		}
 
+	case purgeCache:
+		if nutmegConf.CacheDir == "" {
+			fatal(exitBadInvocation, "No simulation cache configured in nutmeg.conf")
+		}
+		if err := cache.Purge(nutmegConf.CacheDir); err != nil {
+			fatal(exitInfraError, "Failed to purge simulation cache: ", err)
+		}
+
+	case mergeFiles:
+		if flag.NArg() == 0 {
+			fatal(exitBadInvocation, "No result files to merge")
+		}
+		exitCode = mergeResults(flag.Args())
+
 	case descriptionSelectionShort != "":
 		tests := extractTestCases(nutmegConf.TestDir, descriptionSelectionShort,
 			testNames)
@@ -97,9 +229,11 @@ func main() {
 	case categorySelection != "":
 		categorySelection = strings.TrimSpace(categorySelection)
 		categoryMap := extractCategories(nutmegConf, testNames)
-		if ts, ok := categoryMap[categorySelection]; ok {
-			spawnTests(nutmegConf, ts, startTime)
+		ts, ok := categoryMap[categorySelection]
+		if !ok {
+			fatal(exitBadInvocation, "Unknown test category ", categorySelection)
 		}
+		exitCode = spawnTests(nutmegConf, ts, startTime)
 
 	case testSelection != "":
 		testSelection = strings.TrimSpace(testSelection)
@@ -111,11 +245,26 @@ func main() {
 		} else {
 			tests = extractTestCases(nutmegConf.TestDir, testSelection, testNames)
 		}
-		spawnTests(nutmegConf, tests, startTime)
+		if len(tests) == 0 {
+			fatal(exitBadInvocation, "No valid tests selected")
+		}
+		exitCode = spawnTests(nutmegConf, tests, startTime)
+
+	case rerunFailed:
+		tests := extractAllTestCases(nutmegConf.TestDir, testNames)
+		exitCode = spawnTests(nutmegConf, tests, startTime)
 
 	default:
 		flag.PrintDefaults()
+		exitCode = exitBadInvocation
 	}
+	os.Exit(exitCode)
+}
+
+// fatal logs the provided message and exits nutmeg with the given exit code
+func fatal(exitCode int, v ...interface{}) {
+	log.Print(v...)
+	os.Exit(exitCode)
 }
 
 // extractTestCases parses the test selection string and assembles the list
@@ -220,21 +369,336 @@ func extractCategories(conf *tomlParser.Config, testNames []string) map[string][
 	return categoryMap
 }
 
+// acquireLock acquires the session lock of the test output. The lock is
+// located in the output root or the test directory if the output is kept
+// within the test directories.
+func acquireLock(conf *tomlParser.Config) *misc.Lock {
+	lockDir := conf.OutputDir
+	if lockDir == "" {
+		lockDir = conf.TestDir
+	}
+	lock, err := misc.AcquireLock(lockDir)
+	if err != nil {
+		fatal(exitInfraError, err)
+	}
+	return lock
+}
+
+// reproduceCommand returns the nutmeg commandline which reruns the test
+// of the provided result with the same seeds. When analysing the output of a
+// previous run, the master seed of that run is taken from the test's run
+// manifest.
+func reproduceCommand(conf *tomlParser.Config, result *tester.TestResult) string {
+	cmd := []string{os.Args[0]}
+	seed := conf.Seed
+	if analyzeOnly {
+		if s, err := engine.ManifestMasterSeed(result.Path); err == nil {
+			seed = s
+		}
+	}
+	if seed != 0 {
+		cmd = append(cmd, "-seed", strconv.FormatInt(seed, 10))
+	}
+	cmd = append(cmd, "-r", filepath.Base(result.Path))
+	return strings.Join(cmd, " ")
+}
+
 // spawnTests starts the test engine with the user selected tests and
-// prints a status message once they're all finished.
-func spawnTests(conf *tomlParser.Config, tests []string, startTime time.Time) {
-	numGoodTests, badTests, _ := engine.RunTests(conf, tests, numSimJobs, numTestJobs)
+// prints a status message once they're all finished. The failed tests are
+// recorded in the failed tests state file. If requested, only tests which
+// failed previously are run. For dry runs the planned MCell invocations are
+// printed instead. spawnTests returns the exit code describing the outcome
+// of the tests.
+func spawnTests(conf *tomlParser.Config, tests []string, startTime time.Time) int {
+	if shard.Count > 0 {
+		tests = selectShard(tests)
+		if len(tests) == 0 {
+			fmt.Println("No tests to run in this shard")
+			return exitSuccess
+		}
+	}
+
+	failedTests, err := engine.ReadFailedTests(failedTestsFile)
+	if err != nil {
+		fatal(exitInfraError, "Error reading ", failedTestsFile, ": ", err)
+	}
+	if rerunFailed {
+		tests = failedTests.Select(tests)
+		if len(tests) == 0 {
+			fmt.Println("No previously failed tests to run")
+			return exitSuccess
+		}
+	}
+
+	// analysis runs take the lock as well since they read the test output
+	// and may delete it according to the retention policy
+	if !dryRun {
+		lock := acquireLock(conf)
+		defer lock.Release()
+	}
+
+	// pick a master seed unless one was requested so that the run can be
+	// reproduced
+	if conf.Seed == 0 && !analyzeOnly {
+		if conf.CacheDir != "" {
+			conf.Seed = cacheMasterSeed
+		} else {
+			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+			conf.Seed = rng.Int63n(maxMasterSeed) + 1
+		}
+	}
+	if conf.Seed != 0 {
+		fmt.Println("Master seed:", conf.Seed)
+	}
+
+	if dryRun {
+		if engine.DryRun(conf, tests) > 0 {
+			return exitInfraError
+		}
+		return exitSuccess
+	}
+
+	// NOTE: from here on errors are returned instead of calling fatal so that
+	// the deferred release of the lock runs
+	writers, err := createReportWriters()
+	if err != nil {
+		log.Print(err)
+		return exitInfraError
+	}
+	var summary *engine.Summary
+	if analyzeOnly {
+		summary, err = engine.AnalyzeTests(conf, tests, numTestJobs, writers)
+	} else {
+		summary, err = engine.RunTests(conf, tests, numSimJobs, numTestJobs, writers)
+	}
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			log.Print("Failed to write test report: ", err)
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return exitInfraError
+	}
+	numGoodTests := summary.NumGoodTests
+	badTests := summary.BadTests
 	numBadTests := len(badTests)
+	numInterruptedTests := 0
+	for _, t := range badTests {
+		if t.Interrupted {
+			numInterruptedTests++
+		}
+	}
 	fmt.Println("-------------------------------------")
-	fmt.Printf("Ran %d tests in %f s:  SUCCESSES[%d]  FAILURES[%d]\n",
+	fmt.Printf("Ran %d tests in %f s:  SUCCESSES[%d]  FAILURES[%d]",
 		(numGoodTests + numBadTests), time.Since(startTime).Seconds(),
-		numGoodTests, numBadTests)
+		numGoodTests, numBadTests-numInterruptedTests)
+	if numInterruptedTests > 0 {
+		fmt.Printf("  INTERRUPTED[%d]", numInterruptedTests)
+	}
+	fmt.Println()
 
 	if numBadTests > 0 {
 		fmt.Println("")
 		for i, t := range badTests {
-			fmt.Printf("**** FAILED TEST %d: %s :: %s ****\n", i+1, filepath.Base(t.Path), t.TestName)
+			status := "FAILED"
+			if t.Interrupted {
+				status = "INTERRUPTED"
+			}
+			fmt.Printf("**** %s TEST %d: %s :: %s (%s) ****\n", status, i+1,
+				filepath.Base(t.Path), t.TestName, t.Category)
 			fmt.Printf("\n\t%s\n\n", t.ErrorMessage)
+			if t.NumSeeds > 1 {
+				fmt.Printf("\tseeds: %v\n", t.Seeds)
+			} else if t.NumSeeds == 1 {
+				fmt.Printf("\tseed: %d\n", t.Seed)
+			}
+			fmt.Printf("\treproduce with: %s\n\n", reproduceCommand(conf, t))
+		}
+	}
+
+	if numSlowestTests > 0 {
+		printTimings(summary.Timings, numSlowestTests)
+	}
+
+	if reportFixed {
+		fixed := failedTests.Fixed(summary.Tests, badTests)
+		fmt.Printf("\n%d previously failed tests now pass\n", len(fixed))
+		for _, t := range fixed {
+			fmt.Printf(" - %s (previously failed: %s)\n", filepath.Base(t),
+				strings.Join(failedTests[t], ", "))
+		}
+	}
+
+	// only tests which produced results are considered so that the existing
+	// output of tests which were skipped (e.g., after an interrupt) is kept
+	archivePath, err := engine.ApplyRetention(retentionPolicy, summary.Tests,
+		badTests)
+	if err != nil {
+		log.Print("Failed to apply test output retention policy: ", err)
+	} else if archivePath != "" {
+		fmt.Println("\nArchived output of failed tests to", archivePath)
+	}
+
+	failedTests.Update(summary.Tests, badTests)
+	if err := failedTests.Write(failedTestsFile); err != nil {
+		log.Print("Failed to write ", failedTestsFile, ": ", err)
+	}
+	if !analyzeOnly {
+		recordRuntimes(summary.Timings)
+	}
+
+	exitCode := exitSuccess
+	for _, t := range badTests {
+		if t.Category == tester.HarnessError {
+			return exitInfraError
+		}
+		exitCode = exitTestFailures
+	}
+	return exitCode
+}
+
+// createReportWriters sets up the report writers for machine readable test
+// output requested on the command line
+func createReportWriters() ([]report.Writer, error) {
+	var writers []report.Writer
+	if junitReport != "" {
+		w, err := report.NewJUnitWriter(junitReport)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if jsonReport != "" {
+		w, err := report.NewJSONWriter(jsonReport)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	return writers, nil
+}
+
+// mergeResults merges the JSON result files at paths, passes the merged
+// results on to the report writers, and prints a summary listing all failed
+// and conflicting checks. mergeResults returns the exit code describing the
+// merged outcome.
+func mergeResults(paths []string) int {
+	merged, err := engine.MergeResults(paths)
+	if err != nil {
+		fatal(exitInfraError, "Failed to merge result files: ", err)
+	}
+
+	writers, err := createReportWriters()
+	if err != nil {
+		fatal(exitInfraError, err)
+	}
+	numGood, numBad, numConflicts := 0, 0, 0
+	for _, m := range merged {
+		switch {
+		case m.Conflict():
+			numConflicts++
+		case m.Success:
+			numGood++
+		default:
+			numBad++
+		}
+		for _, w := range writers {
+			if err := w.Add(m.TestResult); err != nil {
+				log.Print("Failed to write test result: ", err)
+			}
+		}
+	}
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			log.Print("Failed to write test report: ", err)
+		}
+	}
+
+	fmt.Println("-------------------------------------")
+	fmt.Printf("Merged %d results from %d files:  SUCCESSES[%d]  FAILURES[%d]  CONFLICTS[%d]\n",
+		len(merged), len(paths), numGood, numBad, numConflicts)
+
+	exitCode := exitSuccess
+	i := 0
+	for _, m := range merged {
+		if m.Success {
+			continue
+		}
+		i++
+		status := "FAILED"
+		if m.Conflict() {
+			status = "CONFLICTING"
+		} else if m.Interrupted {
+			status = "INTERRUPTED"
+		}
+		fmt.Printf("\n**** %s TEST %d: %s :: %s (%s) ****\n", status, i,
+			filepath.Base(m.Path), m.TestName, m.Category)
+		fmt.Printf("\n\t%s\n\n", m.ErrorMessage)
+		if len(m.FailedIn) > 0 {
+			fmt.Printf("\tfailed in: %s\n", strings.Join(m.FailedIn, ", "))
+		}
+		if len(m.PassedIn) > 0 {
+			fmt.Printf("\tpassed in: %s\n", strings.Join(m.PassedIn, ", "))
+		}
+		if len(m.InterruptedIn) > 0 {
+			fmt.Printf("\tinterrupted in: %s\n", strings.Join(m.InterruptedIn, ", "))
+		}
+
+		if m.Category == tester.HarnessError {
+			exitCode = exitInfraError
+		} else if exitCode == exitSuccess {
+			exitCode = exitTestFailures
+		}
+	}
+	return exitCode
+}
+
+// selectShard returns the tests of the requested shard, balanced using the
+// runtimes in the shardtimes file if one was provided
+func selectShard(tests []string) []string {
+	var runtimes engine.Runtimes
+	if shardTimes != "" {
+		var err error
+		if runtimes, err = engine.ReadRuntimes(shardTimes); err != nil {
+			fatal(exitInfraError, "Error reading ", shardTimes, ": ", err)
+		}
+	}
+	selection := shard.Select(tests, runtimes)
+	fmt.Printf("Shard %d/%d: %d of %d tests\n", shard.Index, shard.Count,
+		len(selection), len(tests))
+	return selection
+}
+
+// recordRuntimes adds the runtimes of the tests in timings to the runtimes
+// of previous runs
+func recordRuntimes(timings *engine.Timings) {
+	runtimes, err := engine.ReadRuntimes(runtimesFile)
+	if err != nil {
+		log.Print("Error reading ", runtimesFile, ": ", err)
+		return
+	}
+	runtimes.Update(timings)
+	if err := runtimes.Write(runtimesFile); err != nil {
+		log.Print("Failed to write ", runtimesFile, ": ", err)
+	}
+}
+
+// printTimings prints the n slowest tests of a run together with the total
+// time spent running MCell and analysing its output
+func printTimings(timings *engine.Timings, n int) {
+	simTime, simCPUTime, analysisTime := timings.Totals()
+	fmt.Println("")
+	fmt.Printf("Time spent in MCell: %.3f s (CPU: %.3f s)   analysis: %.3f s\n",
+		simTime.Seconds(), simCPUTime.Seconds(), analysisTime.Seconds())
+	fmt.Printf("Slowest tests:\n")
+	for _, t := range timings.Slowest(n) {
+		fmt.Printf("  %-43s %10.3f s  (MCell: %.3f s  CPU: %.3f s  analysis: %.3f s)\n",
+			filepath.Base(t.Path), t.Total().Seconds(), t.SimTime.Seconds(),
+			t.SimCPUTime.Seconds(), t.AnalysisTime.Seconds())
+		if r := t.SlowestRun(); r != nil && len(t.Runs) > 1 {
+			fmt.Printf("  %-43s slowest run: %s with seed %d (%.3f s)\n", "",
+				r.MdlFile, r.Seed, r.Duration.Seconds())
 		}
 	}
 }
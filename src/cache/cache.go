@@ -0,0 +1,412 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cache implements a content addressed cache of MCell simulation
+// output. Cache entries are keyed on a hash of all simulation inputs, i.e.,
+// the mdl files and all files they reference (including the mdl files listed
+// in dynamic geometry files), the commandline options, the wrapper command,
+// the seed, the staged input files and the MCell binary.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/tester"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// names of the files and directories within a cache entry
+const (
+	statusFileName = "status.json"
+	outputDirName  = "output"
+)
+
+// name of the marker file identifying a directory as simulation cache
+const markerFileName = ".nutmeg-cache"
+
+// prefix of the temporary directories in which entries are assembled
+const tmpDirPrefix = ".tmp-"
+
+// quotedString matches the quoted strings in mdl files which may refer to
+// included mdl files or other input files
+var quotedString = regexp.MustCompile(`"([^"\n]+)"`)
+
+// dynamicGeometry matches the DYNAMIC_GEOMETRY statement of mdl files which
+// refers to a file listing the mdl files of the geometry over time
+var dynamicGeometry = regexp.MustCompile(`DYNAMIC_GEOMETRY\s*=\s*"([^"\n]+)"`)
+
+// entryName matches the names of cache entries, i.e., hex encoded SHA256 keys
+var entryName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Cache is a simulation output cache rooted at a directory
+type Cache struct {
+	dir       string // cache directory
+	maxSize   int64  // maximum size of the cache in bytes (0 = unlimited)
+	mcellPath string // path to the mcell executable
+
+	mcellHashOnce sync.Once
+	mcellHash     string
+	mcellHashErr  error
+
+	mutex sync.Mutex // serializes cache eviction
+}
+
+// New returns a Cache rooted at dir for output produced by the mcell
+// executable at mcellPath. If the total size of the cache exceeds maxSize
+// bytes the least recently used entries are evicted. A maxSize of zero means
+// the cache size is unlimited. The cache directory is marked as such via a
+// marker file.
+func New(dir, mcellPath string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	marker := filepath.Join(dir, markerFileName)
+	if err := ioutil.WriteFile(marker, []byte("nutmeg simulation cache\n"), 0644); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, maxSize: maxSize, mcellPath: mcellPath}, nil
+}
+
+// Purge removes all entries from the cache at dir. To guard against
+// misconfigured cache directories, Purge refuses to touch directories without
+// the cache marker file and only removes cache entries and leftover
+// temporary directories.
+func Purge(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, markerFileName)); err != nil {
+		return fmt.Errorf("%s is not a nutmeg simulation cache (missing %s)", dir,
+			markerFileName)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !(entryName.MatchString(name) || strings.HasPrefix(name, tmpDirPrefix)) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key computes the cache key of the simulation run described by test
+func (c *Cache) Key(test *tomlParser.TestDescription) (string, error) {
+	c.mcellHashOnce.Do(func() {
+		c.mcellHash, c.mcellHashErr = hashFile(c.mcellPath)
+	})
+	if c.mcellHashErr != nil {
+		return "", c.mcellHashErr
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "mcell %s\n", c.mcellHash)
+	fmt.Fprintf(h, "seed %d\n", test.Run.Seed)
+	for _, arg := range test.Run.Wrapper {
+		fmt.Fprintf(h, "wrapper %q\n", arg)
+	}
+	for _, opt := range test.Run.CommandlineOpts {
+		fmt.Fprintf(h, "option %q\n", opt)
+	}
+
+	// hash all mdl files in run order together with the files they reference
+	seen := make(map[string]bool)
+	for _, mdlFile := range test.Run.MdlFiles {
+		fmt.Fprintf(h, "run %s\n", mdlFile)
+		if err := hashInput(h, test.Path, filepath.Join(test.Path, mdlFile), seen); err != nil {
+			return "", err
+		}
+	}
+	for _, stageFile := range test.Run.StageFiles {
+		fmt.Fprintf(h, "stage %s\n", stageFile)
+		if err := hashInput(h, test.Path, filepath.Join(test.Path, stageFile), seen); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Restore copies the cached output for key into outputDir and returns the
+// cached status of the simulation runs. The returned bool indicates if the
+// cache contained an entry for key.
+func (c *Cache) Restore(key, outputDir string) ([]tester.RunStatus, bool, error) {
+	entryDir := filepath.Join(c.dir, key)
+	content, err := ioutil.ReadFile(filepath.Join(entryDir, statusFileName))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var status []tester.RunStatus
+	if err := json.Unmarshal(content, &status); err != nil {
+		return nil, false, err
+	}
+	if err := copyTree(filepath.Join(entryDir, outputDirName), outputDir); err != nil {
+		return nil, false, err
+	}
+
+	// mark entry as recently used
+	now := time.Now()
+	os.Chtimes(entryDir, now, now)
+	// cached runs took no simulation time
+	for i := range status {
+		status[i].Cached = true
+		status[i].Duration = 0
+		status[i].CPUTime = 0
+	}
+	return status, true, nil
+}
+
+// Store adds the content of outputDir and the status of the corresponding
+// simulation runs to the cache under key.
+func (c *Cache) Store(key, outputDir string, status []tester.RunStatus) error {
+	content, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	// assemble entry in a temporary directory first so concurrent readers
+	// never see a partial entry
+	tmpDir, err := ioutil.TempDir(c.dir, tmpDirPrefix+key)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := copyTree(outputDir, filepath.Join(tmpDir, outputDirName)); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, statusFileName), content, 0644); err != nil {
+		return err
+	}
+
+	entryDir := filepath.Join(c.dir, key)
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		// somebody else already stored this entry
+		if _, statErr := os.Stat(entryDir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return c.evict()
+}
+
+// evict removes the least recently used cache entries until the size of the
+// cache is below its maximum size
+func (c *Cache) evict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var cacheEntries []entry
+	var totalSize int64
+	for _, e := range entries {
+		if !e.IsDir() || !entryName.MatchString(e.Name()) {
+			continue
+		}
+		path := filepath.Join(c.dir, e.Name())
+		size, err := treeSize(path)
+		if err != nil {
+			return err
+		}
+		cacheEntries = append(cacheEntries, entry{path, size, e.ModTime()})
+		totalSize += size
+	}
+
+	sort.Slice(cacheEntries, func(i, j int) bool {
+		return cacheEntries[i].modTime.Before(cacheEntries[j].modTime)
+	})
+	for _, e := range cacheEntries {
+		if totalSize <= c.maxSize {
+			break
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			return err
+		}
+		totalSize -= e.size
+	}
+	return nil
+}
+
+// hashInput adds the content of the input file at path to h. For mdl files,
+// all files referenced via quoted strings which exist relative to the
+// directory of the mdl file are hashed recursively as are the mdl files
+// listed in dynamic geometry files. File names are recorded relative to the
+// test directory testDir.
+func hashInput(h hash.Hash, testDir, path string, seen map[string]bool) error {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(testDir, path)
+	if err != nil {
+		name = path
+	}
+	fmt.Fprintf(h, "file %s %d\n", name, len(content))
+	h.Write(content)
+
+	if filepath.Ext(path) != ".mdl" {
+		return nil
+	}
+	for _, m := range quotedString.FindAllStringSubmatch(string(content), -1) {
+		refPath := filepath.Join(filepath.Dir(path), m[1])
+		if fi, err := os.Stat(refPath); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := hashInput(h, testDir, refPath, seen); err != nil {
+			return err
+		}
+	}
+	for _, m := range dynamicGeometry.FindAllStringSubmatch(string(content), -1) {
+		listPath := filepath.Join(filepath.Dir(path), m[1])
+		if err := hashGeometryList(h, testDir, listPath, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashGeometryList hashes the mdl files listed in the dynamic geometry file
+// at listPath. Each line of the file consists of a time and the path of the
+// mdl file describing the geometry from that time on, relative to the
+// directory of the dynamic geometry file. The dynamic geometry file itself
+// is hashed as part of the referencing mdl file.
+func hashGeometryList(h hash.Hash, testDir, listPath string,
+	seen map[string]bool) error {
+
+	content, err := ioutil.ReadFile(listPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		geomPath := filepath.Join(filepath.Dir(listPath), fields[1])
+		if fi, err := os.Stat(geomPath); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := hashInput(h, testDir, geomPath, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashFile returns the hex encoded SHA256 hash of the file at path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyTree recursively copies the regular files, directories and symlinks
+// in src to dest
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, 0755)
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case fi.Mode().IsRegular():
+			return copyFile(path, target, fi.Mode())
+		}
+		return nil
+	})
+}
+
+// copyFile copies the regular file at src to dest
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// treeSize returns the total size of all regular files below path
+func treeSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,122 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/misc"
+	"github.com/mcellteam/nutmeg/src/report"
+	"github.com/mcellteam/nutmeg/src/tester"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// AnalyzeTests runs the checks of the specified list of tests against the
+// simulation output of a previous run without running MCell. All test results
+// are passed on to the provided report writers as they become available.
+// If nutmeg receives SIGINT or SIGTERM no further tests are analysed.
+func AnalyzeTests(conf *tomlParser.Config, tests []string, numTestJobs int,
+	writers []report.Writer) (*Summary, error) {
+
+	timings := newTimings()
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go handleInterrupts(stop, finished)
+
+	testResults := make(chan *tester.TestResult, len(tests))
+	testInput := make(chan *tester.TestData, len(tests))
+	go createAnalysisJobs(conf.IncludeDir, tests, testInput, testResults, stop)
+
+	return runChecks(testInput, testResults, numTestJobs, writers, timings), nil
+}
+
+// createAnalysisJobs reconstructs the TestData of each test from its test
+// description and the run manifest in its existing output directory and hands
+// it to the tester. The seeds and the status of all simulation runs are taken
+// from the manifest. Once stop is closed no further tests are handed out.
+func createAnalysisJobs(includePath string, testPaths []string,
+	testInput chan *tester.TestData, testResults chan *tester.TestResult,
+	stop <-chan struct{}) {
+
+	for _, testDir := range testPaths {
+		if misc.IsClosed(stop) {
+			break
+		}
+		testFile := filepath.Join(testDir, "test_description.toml")
+		testDescription, err := tomlParser.Parse(testFile, includePath)
+		if err != nil {
+			msg := fmt.Sprintf("Error parsing test description in %s: %v", testDir, err)
+			testResults <- &tester.TestResult{Path: testDir, Success: false,
+				TestName: "parse description", ErrorMessage: msg, CheckIndex: -1,
+				Category: tester.HarnessError}
+			continue
+		}
+		testDescription.Path = testDir
+
+		manifest, err := readManifest(testDir)
+		if err == nil && len(manifest.Runs) == 0 {
+			err = fmt.Errorf("no simulation runs recorded")
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Failed to read run manifest of previous run: %v", err)
+			testResults <- &tester.TestResult{Path: testDir, Success: false,
+				TestName: "read test output", ErrorMessage: msg, CheckIndex: -1,
+				Category: tester.HarnessError}
+			continue
+		}
+
+		seeds := manifestSeeds(manifest)
+		testDescription.Run.NumSeeds = len(seeds)
+		testDescription.Run.Seeds = seeds
+		testDescription.Run.Seed = seeds[len(seeds)-1]
+
+		testInput <- &tester.TestData{TestDescription: testDescription,
+			SimStatus: reconstructRunStatus(testDir, manifest, len(seeds))}
+	}
+	close(testInput)
+}
+
+// manifestSeeds returns the seeds of all runs recorded in manifest in the
+// order they were run
+func manifestSeeds(manifest *Manifest) []int {
+	var seeds []int
+	seen := make(map[int]bool)
+	for _, inv := range manifest.Runs {
+		if !seen[inv.Seed] {
+			seen[inv.Seed] = true
+			seeds = append(seeds, inv.Seed)
+		}
+	}
+	return seeds
+}
+
+// reconstructRunStatus determines the status of all runs recorded in the
+// manifest of the test at testDir. The stderr output of failed runs is read
+// back from MCell's error files in the run directories.
+func reconstructRunStatus(testDir string, manifest *Manifest,
+	numSeeds int) []tester.RunStatus {
+
+	var status []tester.RunStatus
+	mdlIndex := make(map[int]int)
+	for _, inv := range manifest.Runs {
+		s := inv.runStatus()
+		i := mdlIndex[inv.Seed]
+		mdlIndex[inv.Seed]++
+		if !s.Success {
+			runDir := file.GetRunDir(testDir, inv.Seed, numSeeds)
+			errLog := fmt.Sprintf("err_%d.%d.log", inv.Seed, i)
+			if stdErr, err := ioutil.ReadFile(filepath.Join(runDir, errLog)); err == nil {
+				s.StdErrContent = string(stdErr)
+			}
+		}
+		status = append(status, s)
+	}
+	return status
+}
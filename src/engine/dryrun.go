@@ -0,0 +1,86 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/tester"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// DryRun prints the MCell invocations planned for the provided tests
+// together with their working directories and the checks which would be
+// applied to their output. It walks the same path as RunTests, i.e., test
+// includes are resolved and seeds are assigned identically, but neither
+// output directories are created nor is MCell run. DryRun returns the number
+// of tests whose description could not be parsed or contains unknown checks.
+func DryRun(conf *tomlParser.Config, tests []string) int {
+	numBadTests := 0
+	for runID, testDir := range tests {
+		test, err := planTest(conf.IncludeDir, conf.Seed, testDir, runID)
+		if err == nil {
+			err = checkTestTypes(test)
+		}
+		if err != nil {
+			fmt.Printf("%s\n\tERROR: %v\n\n", filepath.Base(testDir), err)
+			numBadTests++
+			continue
+		}
+		if len(test.Run.Wrapper) == 0 {
+			test.Run.Wrapper = conf.Wrapper
+		}
+
+		fmt.Println(filepath.Base(testDir))
+		if timeout := runTimeout(conf, test); timeout > 0 {
+			fmt.Printf("\ttimeout: %v\n", timeout)
+		}
+		for _, seedTest := range seedRuns(test) {
+			runDir := file.GetRunDir(testDir, seedTest.Run.Seed, seedTest.Run.NumSeeds)
+			fmt.Printf("\tseed %d in %s\n", seedTest.Run.Seed, runDir)
+			for _, f := range seedTest.Run.StageFiles {
+				fmt.Printf("\t\tstage %s\n", filepath.Join(testDir, f))
+			}
+			for i := range seedTest.Run.MdlFiles {
+				cmdLine, _ := mcellCommand(conf.McellPath, seedTest, i)
+				fmt.Printf("\t\t%s\n", strings.Join(cmdLine, " "))
+			}
+		}
+
+		fmt.Println("\tchecks:")
+		for _, c := range test.Checks {
+			check := c.TestType
+			if c.DataFile != "" {
+				check += " on " + c.DataFile
+			}
+			if c.Description != "" {
+				check += " -- " + c.Description
+			}
+			fmt.Printf("\t\t%s\n", check)
+		}
+		fmt.Println()
+	}
+	return numBadTests
+}
+
+// checkTestTypes verifies that a check is registered for the testType of
+// each of the test's checks. During regular runs checks with unknown test
+// types only fail individually once the simulations are done.
+func checkTestTypes(test *tomlParser.TestDescription) error {
+	known := make(map[string]bool)
+	for _, t := range tester.Checkers() {
+		known[t] = true
+	}
+	for i, c := range test.Checks {
+		if !known[c.TestType] {
+			return fmt.Errorf("Invalid test description in %s: unknown test type %q "+
+				"of check %d", test.Path, c.TestType, i)
+		}
+	}
+	return nil
+}
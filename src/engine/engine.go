@@ -8,126 +8,248 @@ package engine
 
 import (
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/mcellteam/nutmeg/src/cache"
 	"github.com/mcellteam/nutmeg/src/file"
 	"github.com/mcellteam/nutmeg/src/misc"
+	"github.com/mcellteam/nutmeg/src/report"
 	"github.com/mcellteam/nutmeg/src/tester"
 	"github.com/mcellteam/nutmeg/src/tomlParser"
 )
 
-// initialize random number generator
-var rng *rand.Rand
+// maximum seed value derived from the master seed
+const maxDerivedSeed = 10000
 
-func init() {
-	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+// maximum time allowed for extracting a backtrace from a core file
+const backtraceTimeout = time.Minute
+
+// Summary describes the outcome of a test run
+type Summary struct {
+	NumGoodTests int                  // number of successful tests
+	BadTests     []*tester.TestResult // failed tests
+	Tests        []string             // paths of all tests which produced results
+	Timings      *Timings             // time spent on simulations and checks
 }
 
-// RunTests runs the specified list of tests
+// RunTests runs the specified list of tests. All test results are passed
+// on to the provided report writers as they become available.
+// If nutmeg receives SIGINT or SIGTERM no further tests are scheduled, all
+// running simulations are killed and their tests reported as interrupted.
+// If the configuration provides a cache directory, the output of simulations
+// whose inputs did not change is restored from the cache instead of running
+// MCell.
 func RunTests(conf *tomlParser.Config, tests []string,
-	numSimJobs, numTestJobs int) (int, []*tester.TestResult, error) {
+	numSimJobs, numTestJobs int, writers []report.Writer) (*Summary, error) {
 
 	if err := misc.CleanOutput(tests); err != nil {
 		fmt.Println("Failed to clean up previous test results", err)
-		return 0, nil, err
+		return nil, err
+	}
+
+	var simCache *cache.Cache
+	if conf.CacheDir != "" {
+		var err error
+		maxSize := int64(conf.CacheSize * 1024 * 1024)
+		if simCache, err = cache.New(conf.CacheDir, conf.McellPath, maxSize); err != nil {
+			fmt.Println("Failed to set up simulation cache", err)
+			return nil, err
+		}
 	}
 
+	timings := newTimings()
+	mcell := newMCellInfo(conf.McellPath)
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go handleInterrupts(stop, finished)
+
 	testResults := make(chan *tester.TestResult, len(tests))
 	simJobs := make(chan *tester.TestData, numSimJobs)
-	go createSimJobs(conf.IncludeDir, tests, simJobs, testResults)
+	go createSimJobs(conf.IncludeDir, conf.Seed, tests, simJobs, testResults, stop)
 
 	// framework for running simulations
 	simOutput := make(chan *tester.TestData, len(tests))
 	simsDone := make(chan struct{}, numSimJobs)
 	for i := 0; i < numSimJobs; i++ {
-		go runSimJobs(conf.McellPath, simOutput, simJobs, simsDone)
+		go runSimJobs(conf, simCache, simOutput, simJobs, simsDone, stop)
 	}
 	go closeSimOutput(simOutput, simsDone, numSimJobs)
 
 	// framework for collecting simulation results and funneling them into tests
 	testInput := make(chan *tester.TestData, len(tests))
-	go collectSimResults(testInput, simOutput)
+	go collectSimResults(testInput, simOutput, timings, mcell, conf.Seed)
+
+	return runChecks(testInput, testResults, numTestJobs, writers, timings), nil
+}
+
+// runChecks runs the checks of all tests arriving via testInput and processes
+// their results.
+func runChecks(testInput chan *tester.TestData, testResults chan *tester.TestResult,
+	numTestJobs int, writers []report.Writer, timings *Timings) *Summary {
 
 	// framework for running tests
 	testsDone := make(chan struct{}, numTestJobs)
 	for i := 0; i < numTestJobs; i++ {
 		go runTestJobs(testResults, testInput, testsDone)
 	}
-	numGoodTests, badTests := processResults(testResults, testsDone, numTestJobs)
-	return numGoodTests, badTests, nil
+	numGoodTests, badTests, tests := processResults(testResults, testsDone,
+		numTestJobs, writers, timings)
+	return &Summary{NumGoodTests: numGoodTests, BadTests: badTests, Tests: tests,
+		Timings: timings}
+}
+
+// handleInterrupts closes stop once nutmeg receives SIGINT or SIGTERM. After
+// the first signal the default signal handling is restored so that a second
+// one terminates nutmeg right away. handleInterrupts returns once finished
+// is closed.
+func handleInterrupts(stop, finished chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	select {
+	case <-sigs:
+		fmt.Println("\nInterrupted -- terminating running simulations")
+		close(stop)
+	case <-finished:
+	}
 }
 
 // collectSimResults collects all simulation results (e.g. multiple Seeds) for
 // a single test case and dispatches them to the tester once they are done.
+// The simulation results of multi seed tests are gathered per test via their
+// RunID. The timings of all simulation runs are recorded in timings and
+// each completed test's run manifest is written to its output directory.
 func collectSimResults(testInput chan *tester.TestData,
-	simOutput chan *tester.TestData) {
+	simOutput chan *tester.TestData, timings *Timings, mcell MCellInfo,
+	masterSeed int64) {
 
-	simMap := make(map[int]int)
-	var simResultsAccum []tester.RunStatus
+	pendingSims := make(map[int][]*tester.TestData)
 	for sim := range simOutput {
+		timings.addRuns(sim.Path, sim.SimStatus)
 
-		numSeeds := sim.Run.NumSeeds
 		// for a single Seed run we can forward the output to the testing framework right away
-		if numSeeds == 1 {
+		if sim.Run.NumSeeds == 1 {
+			recordManifest(mcell, masterSeed, sim)
 			testInput <- sim
-		} else {
-			id := sim.Run.RunID
-			if v, ok := simMap[id]; ok {
-				simMap[id] = v + 1
-			} else {
-				simMap[id] = 1
-			}
-
-			simResultsAccum = append(simResultsAccum, sim.SimStatus...)
+			continue
+		}
 
-			if simMap[id] == numSeeds {
-				// append final list of results
-				sim.SimStatus = simResultsAccum
-				testInput <- sim
-			}
+		id := sim.Run.RunID
+		sims := append(pendingSims[id], sim)
+		if len(sims) < sim.Run.NumSeeds {
+			pendingSims[id] = sims
+			continue
 		}
+		delete(pendingSims, id)
+		merged := mergeSimResults(sims)
+		recordManifest(mcell, masterSeed, merged)
+		testInput <- merged
 	}
 	close(testInput)
 }
 
-// simRunner runs mcell on the mdl file passed in as an
-// absolute path. The working directory is set to the base path
-// of the mdl file.
-func simRunner(mcellPath string, test *tester.TestData,
-	output chan *tester.TestData) {
+// recordManifest writes the run manifest of test and logs any failure to do so
+func recordManifest(mcell MCellInfo, masterSeed int64, test *tester.TestData) {
+	if err := writeManifest(mcell, masterSeed, test); err != nil {
+		log.Printf("Failed to write run manifest of %s: %v", test.Path, err)
+	}
+}
 
-	outputDir := file.GetOutputDir(test.Path)
-	for i, runFile := range test.Run.MdlFiles {
-		// create run command
-		mdlPath := filepath.Join(test.Path, runFile)
-		runLog := fmt.Sprintf("run_%d.%d.log", test.Run.Seed, i)
-		errLog := fmt.Sprintf("err_%d.%d.log", test.Run.Seed, i)
-		argList := append(test.Run.CommandlineOpts, "-seed", strconv.Itoa(test.Run.Seed),
-			"-logfile", runLog, "-errfile", errLog, mdlPath)
-		cmd := exec.Command(mcellPath, argList...)
-		cmd.Dir = outputDir
-
-		if err := misc.WriteCmdLine(mcellPath, outputDir, argList); err != nil {
-			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
-				ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1})
+// mergeSimResults combines the simulation results of all seeds of a multi
+// seed test into a single TestData. The RunStatus entries are ordered
+// according to the test's list of seeds.
+func mergeSimResults(sims []*tester.TestData) *tester.TestData {
+	seedIndex := make(map[int]int)
+	for i, seed := range sims[0].Run.Seeds {
+		if _, ok := seedIndex[seed]; !ok {
+			seedIndex[seed] = i
+		}
+	}
+	sort.SliceStable(sims, func(i, j int) bool {
+		return seedIndex[sims[i].Run.Seed] < seedIndex[sims[j].Run.Seed]
+	})
+
+	var status []tester.RunStatus
+	for _, sim := range sims {
+		status = append(status, sim.SimStatus...)
+	}
+	// the test description of the last seed is the one describing the whole run
+	return &tester.TestData{TestDescription: sims[len(sims)-1].TestDescription,
+		SimStatus: status}
+}
+
+// simRunner runs mcell on the mdl file passed in as an absolute path. The
+// working directory is set to the run directory of the test's seed (see
+// file.GetRunDir). MCell runs exceeding the test's timeout (or the default
+// timeout from the configuration if the test doesn't set one) are killed.
+// MCell is run via the wrapper command of the test or, if the test doesn't
+// set one, the default wrapper from the configuration. Once stop is closed
+// running MCell jobs are killed and no further mdl files are run. If
+// simCache is non-nil, cached output is restored instead of running MCell and
+// the output of new runs is added to the cache.
+func simRunner(conf *tomlParser.Config, simCache *cache.Cache, test *tester.TestData,
+	output chan *tester.TestData, stop <-chan struct{}) {
+
+	mcellPath := conf.McellPath
+	timeoutDuration := runTimeout(conf, test.TestDescription)
+	if len(test.Run.Wrapper) == 0 {
+		test.Run.Wrapper = conf.Wrapper
+	}
+
+	runDir := file.GetRunDir(test.Path, test.Run.Seed, test.Run.NumSeeds)
+	if err := prepareRunDir(test, runDir); err != nil {
+		test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
+			ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1,
+			HarnessError: true, Seed: test.Run.Seed})
+		output <- test
+		return
+	}
+
+	var cacheKey string
+	if simCache != nil {
+		var hit bool
+		if cacheKey, hit = restoreFromCache(simCache, mcellPath, test, runDir); hit {
 			output <- test
 			return
 		}
+	}
+
+	for i, runFile := range test.Run.MdlFiles {
+		if misc.IsClosed(stop) {
+			msg := fmt.Sprintf("nutmeg was interrupted before %s was run", runFile)
+			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
+				ExitMessage: msg, ExitCode: -1, Interrupted: true, MdlFile: runFile,
+				Seed: test.Run.Seed})
+			break
+		}
+
+		// create run command
+		cmdLine, errLog := mcellCommand(mcellPath, test.TestDescription, i)
+		cmd := exec.Command(cmdLine[0], cmdLine[1:]...)
+		cmd.Dir = runDir
 
 		// connect stdout and stderr
 		stdOutPath := fmt.Sprintf("stdout_%d.%d.log", test.Run.Seed, i)
-		stdOut, err := os.Create(filepath.Join(outputDir, stdOutPath))
+		stdOut, err := os.Create(filepath.Join(runDir, stdOutPath))
 		if err != nil {
 			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
-				ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1})
+				ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1,
+				HarnessError: true, MdlFile: runFile, Seed: test.Run.Seed})
 			output <- test
 			return
 		}
@@ -135,86 +257,287 @@ func simRunner(mcellPath string, test *tester.TestData,
 		cmd.Stdout = stdOut
 
 		stdErrPath := fmt.Sprintf("stderr_%d.%d.log", test.Run.Seed, i)
-		stdErr, err := os.Create(filepath.Join(outputDir, stdErrPath))
+		stdErr, err := os.Create(filepath.Join(runDir, stdErrPath))
 		if err != nil {
 			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
-				ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1})
+				ExitMessage: fmt.Sprint(err), StdErrContent: "", ExitCode: -1,
+				HarnessError: true, MdlFile: runFile, Seed: test.Run.Seed})
 			output <- test
 			return
 		}
 		defer stdErr.Close()
 		cmd.Stderr = stdErr
 
-		err = cmd.Run()
-		if err != nil {
-			stdErr, _ := ioutil.ReadFile(filepath.Join(outputDir, errLog))
-			exitCode, err := misc.DetermineExitCode(err)
-			if err != nil {
+		start := time.Now()
+		err = misc.RunCommand(cmd, timeoutDuration, stop)
+		status := tester.RunStatus{MdlFile: runFile, Seed: test.Run.Seed,
+			Args: cmdLine, Dir: runDir, Start: start, Duration: time.Since(start)}
+		if cmd.ProcessState != nil {
+			status.CPUTime = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		}
+
+		if err == misc.ErrInterrupted {
+			status.ExitMessage = fmt.Sprintf("nutmeg was interrupted while running %s", runFile)
+			status.ExitCode = -1
+			status.Interrupted = true
+			test.SimStatus = append(test.SimStatus, status)
+			break
+		} else if err == misc.ErrTimedOut {
+			stdErr, _ := ioutil.ReadFile(filepath.Join(runDir, errLog))
+			status.ExitMessage = fmt.Sprintf("%s did not finish within %v and was killed",
+				runFile, timeoutDuration)
+			status.StdErrContent = string(stdErr)
+			status.ExitCode = -1
+			status.TimedOut = true
+		} else if err != nil {
+			stdErr, _ := ioutil.ReadFile(filepath.Join(runDir, errLog))
+			exitCode, codeErr := misc.DetermineExitCode(err)
+			if codeErr != nil {
 				exitCode = -1
 			}
-			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: false,
-				ExitMessage: fmt.Sprint(err), StdErrContent: string(stdErr), ExitCode: exitCode})
+			status.ExitMessage = fmt.Sprint(err)
+			status.StdErrContent = string(stdErr)
+			status.ExitCode = exitCode
+			// mcell could not be started at all
+			status.HarnessError = cmd.ProcessState == nil
+			if signal, coreDumped := misc.DetermineSignal(err); signal != "" {
+				status.Signal = signal
+				status.CoreDumped = coreDumped
+				status.ExitMessage = fmt.Sprintf("%s was terminated by %s", runFile, signal)
+				if coreDumped {
+					status.ExitMessage += " and dumped core"
+				}
+				if conf.Backtrace && coreDumped {
+					status.Backtrace = coreBacktrace(mcellPath, runDir, cmd.Process.Pid)
+				}
+			}
 		} else {
-			test.SimStatus = append(test.SimStatus, tester.RunStatus{Success: true,
-				ExitMessage: "", StdErrContent: "",
-				ExitCode: 0})
+			status.Success = true
+		}
+		test.SimStatus = append(test.SimStatus, status)
+	}
+
+	if cacheKey != "" && isCacheable(test.SimStatus) {
+		if err := simCache.Store(cacheKey, runDir, test.SimStatus); err != nil {
+			log.Printf("Failed to add %s to simulation cache: %v", test.Path, err)
 		}
 	}
 	output <- test
 }
 
+// coreBacktrace extracts the backtrace from the core file dumped into runDir
+// by the crashed mcell process with the given pid. If the backtrace can't be
+// determined the reason is returned instead.
+func coreBacktrace(mcellPath, runDir string, pid int) string {
+	coreFile := misc.FindCoreFile(runDir, pid)
+	if coreFile == "" {
+		return "no core file found in " + runDir
+	}
+	backtrace, err := misc.Backtrace(mcellPath, coreFile, backtraceTimeout)
+	if err != nil {
+		return fmt.Sprintf("failed to extract backtrace from %s: %v\n%s", coreFile,
+			err, backtrace)
+	}
+	return backtrace
+}
+
+// runTimeout returns the timeout of each MCell run of test, i.e., the test's
+// timeout or the default timeout from the configuration if the test doesn't
+// set one
+func runTimeout(conf *tomlParser.Config, test *tomlParser.TestDescription) time.Duration {
+	timeout := test.Run.Timeout
+	if timeout <= 0 {
+		timeout = conf.Timeout
+	}
+	return time.Duration(timeout * float64(time.Second))
+}
+
+// mcellCommand assembles the commandline for running the i-th mdl file of
+// test prefixed by the test's (possibly empty) wrapper command. The
+// placeholders {seed} and {mdl} in the wrapper arguments are replaced by the
+// seed and the index of the mdl file so that each run of a chained test can
+// write its own report, e.g., via valgrind's --xml-file option. mcellCommand
+// also returns the name of MCell's error file.
+func mcellCommand(mcellPath string, test *tomlParser.TestDescription,
+	i int) ([]string, string) {
+
+	mdlPath := filepath.Join(test.Path, test.Run.MdlFiles[i])
+	runLog := fmt.Sprintf("run_%d.%d.log", test.Run.Seed, i)
+	errLog := fmt.Sprintf("err_%d.%d.log", test.Run.Seed, i)
+
+	placeholders := strings.NewReplacer("{seed}", strconv.Itoa(test.Run.Seed),
+		"{mdl}", strconv.Itoa(i))
+	var cmdLine []string
+	for _, arg := range test.Run.Wrapper {
+		cmdLine = append(cmdLine, placeholders.Replace(arg))
+	}
+	cmdLine = append(cmdLine, mcellPath)
+	cmdLine = append(cmdLine, test.Run.CommandlineOpts...)
+	cmdLine = append(cmdLine, "-seed", strconv.Itoa(test.Run.Seed),
+		"-logfile", runLog, "-errfile", errLog, mdlPath)
+	return cmdLine, errLog
+}
+
+// prepareRunDir creates the working directory runDir of a simulation run
+// and stages the test's input files requested via stageFiles into it.
+func prepareRunDir(test *tester.TestData, runDir string) error {
+	if err := os.MkdirAll(runDir, 0744); err != nil {
+		return err
+	}
+	for _, f := range test.Run.StageFiles {
+		dest := filepath.Join(runDir, filepath.Base(f))
+		if err := misc.CopyFile(filepath.Join(test.Path, f), dest); err != nil {
+			return fmt.Errorf("failed to stage input file %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// restoreFromCache restores the output of test from simCache into runDir
+// and appends the cached run status to the test's SimStatus. The commandline
+// and working directory of the cached runs are replaced by the ones of the
+// current run since the cached output may have been produced elsewhere. It
+// returns the cache key of the test (empty if none could be computed) and
+// whether the output was restored.
+func restoreFromCache(simCache *cache.Cache, mcellPath string, test *tester.TestData,
+	runDir string) (string, bool) {
+
+	key, err := simCache.Key(test.TestDescription)
+	if err != nil {
+		log.Printf("Failed to compute simulation cache key for %s: %v", test.Path, err)
+		return "", false
+	}
+
+	status, hit, err := simCache.Restore(key, runDir)
+	if err != nil {
+		log.Printf("Failed to restore %s from simulation cache: %v", test.Path, err)
+		return key, false
+	} else if !hit {
+		return key, false
+	}
+	for i := range status {
+		status[i].Dir = runDir
+		if i < len(test.Run.MdlFiles) && status[i].MdlFile == test.Run.MdlFiles[i] {
+			status[i].Args, _ = mcellCommand(mcellPath, test.TestDescription, i)
+		}
+	}
+	test.SimStatus = append(test.SimStatus, status...)
+	return key, true
+}
+
+// isCacheable checks if the outcome of the provided simulation runs only
+// depends on their inputs. Timed out, interrupted, or otherwise failed to
+// run simulations are never cached.
+func isCacheable(status []tester.RunStatus) bool {
+	if len(status) == 0 {
+		return false
+	}
+	for _, s := range status {
+		if s.TimedOut || s.Interrupted || s.HarnessError {
+			return false
+		}
+	}
+	return true
+}
+
 // createSimJobs is responsible for filling a worker queue with
 // jobs to be run via the simulation tool. It parses the test
-// description, assembles a TestDescription struct and adds it
-// to the simulation job queue.
-func createSimJobs(includePath string, testPaths []string,
-	simJobs chan *tester.TestData, testResults chan *tester.TestResult) {
+// description, assembles a TestDescription struct per Seed and adds them
+// to the simulation job queue. The seeds of single seed runs are derived
+// from masterSeed. Once stop is closed no further tests are scheduled.
+func createSimJobs(includePath string, masterSeed int64, testPaths []string,
+	simJobs chan *tester.TestData, testResults chan *tester.TestResult,
+	stop <-chan struct{}) {
 	runID := 0
 	for _, testDir := range testPaths {
-		testFile := filepath.Join(testDir, "test_description.toml")
-		testDescription, err := tomlParser.Parse(testFile, includePath)
+		if misc.IsClosed(stop) {
+			break
+		}
+
+		testDescription, err := planTest(includePath, masterSeed, testDir, runID)
 		if err != nil {
-			msg := fmt.Sprintf("Error parsing test description in %s: %v", testDir, err)
-			testResults <- &tester.TestResult{Path: testFile, Success: false,
-				TestName: "parse description", ErrorMessage: msg}
+			testResults <- &tester.TestResult{Path: testDir, Success: false,
+				TestName: "parse description", ErrorMessage: fmt.Sprint(err),
+				CheckIndex: -1, Category: tester.HarnessError}
 			continue
 		}
 
 		// create output directory
 		outputDir := file.GetOutputDir(testDir)
-		if err := os.Mkdir(outputDir, 0744); err != nil {
+		if err := os.MkdirAll(outputDir, 0744); err != nil {
 			msg := fmt.Sprint(err)
-			testResults <- &tester.TestResult{Path: testFile, Success: false,
-				TestName: "create test output directory", ErrorMessage: msg}
+			testResults <- &tester.TestResult{Path: testDir, Success: false,
+				TestName: "create test output directory", ErrorMessage: msg,
+				CheckIndex: -1, Category: tester.HarnessError}
 			continue
 		}
 
-		// set path and pick a Seed value for run
-		testDescription.Path = testDir
-		testDescription.Run.RunID = runID
-
-		// schedule requested number of Seeds; if there is just a single
-		// Seed requested we pick one randomly
-		switch testDescription.Run.NumSeeds {
-		case 0: // user didn't set number of Seeds -- assume single Seed
-			testDescription.Run.NumSeeds = 1
-			testDescription.Run.Seed = rng.Intn(10000)
-		case 1:
-			testDescription.Run.Seed = rng.Intn(10000)
-		default:
-			for i := 1; i < testDescription.Run.NumSeeds; i++ {
-				newTest := testDescription.Copy()
-				newTest.Run.Seed = i
-				testDescription.Run.Seed = i + 1
-				simJobs <- &tester.TestData{newTest, nil}
-			}
+		// schedule one job per Seed
+		for _, seedTest := range seedRuns(testDescription) {
+			simJobs <- &tester.TestData{TestDescription: seedTest}
 		}
-		simJobs <- &tester.TestData{testDescription, nil}
 		runID++
 	}
 	close(simJobs)
 }
 
+// planTest parses the test description of the test at testDir, sets its path
+// and run ID, and picks the Seed values for its runs. The seeds of single
+// seed runs are derived from masterSeed.
+func planTest(includePath string, masterSeed int64, testDir string,
+	runID int) (*tomlParser.TestDescription, error) {
+
+	testFile := filepath.Join(testDir, "test_description.toml")
+	testDescription, err := tomlParser.Parse(testFile, includePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing test description in %s: %v", testDir, err)
+	}
+
+	testDescription.Path = testDir
+	testDescription.Run.RunID = runID
+	seeds := testSeeds(masterSeed, testDir, testDescription.Run)
+	testDescription.Run.NumSeeds = len(seeds)
+	testDescription.Run.Seeds = seeds
+	return testDescription, nil
+}
+
+// seedRuns returns one test description per Seed of test. The description
+// of the last Seed is test itself.
+func seedRuns(test *tomlParser.TestDescription) []*tomlParser.TestDescription {
+	seeds := test.Run.Seeds
+	var runs []*tomlParser.TestDescription
+	for _, seed := range seeds[:len(seeds)-1] {
+		newTest := test.Copy()
+		newTest.Run.Seed = seed
+		runs = append(runs, newTest)
+	}
+	test.Run.Seed = seeds[len(seeds)-1]
+	return append(runs, test)
+}
+
+// testSeeds returns the seeds to run for the test at testPath. Explicitly
+// requested seeds take precedence, multi seed runs use seeds 1 through
+// NumSeeds, and the seed of single seed runs is derived from masterSeed and
+// the test name. Thus, rerunning a test with the same master seed reproduces
+// its seed independent of the other selected tests.
+func testSeeds(masterSeed int64, testPath string, run tomlParser.RunSpec) []int {
+	if len(run.Seeds) > 0 {
+		return run.Seeds
+	}
+
+	if run.NumSeeds > 1 {
+		seeds := make([]int, run.NumSeeds)
+		for i := range seeds {
+			seeds[i] = i + 1
+		}
+		return seeds
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s", masterSeed, filepath.Base(testPath))
+	return []int{int(h.Sum64()%maxDerivedSeed) + 1}
+}
+
 // ShowTestDescription shows the description for the selected set of
 // tests.
 func ShowTestDescription(conf *tomlParser.Config, testPaths []string) {
@@ -234,10 +557,11 @@ func ShowTestDescription(conf *tomlParser.Config, testPaths []string) {
 
 // runSimJobs loops over all available jobs and runs each of
 // them in a simRunner.
-func runSimJobs(mcellPath string, simOutput chan *tester.TestData,
-	simJobs <-chan *tester.TestData, simsDone chan struct{}) {
+func runSimJobs(conf *tomlParser.Config, simCache *cache.Cache,
+	simOutput chan *tester.TestData, simJobs <-chan *tester.TestData,
+	simsDone chan struct{}, stop <-chan struct{}) {
 	for job := range simJobs {
-		simRunner(mcellPath, job, simOutput)
+		simRunner(conf, simCache, job, simOutput, stop)
 	}
 	simsDone <- struct{}{}
 }
@@ -264,22 +588,41 @@ func runTestJobs(results chan *tester.TestResult, simOutput <-chan *tester.TestD
 }
 
 // processResults process all produced test results and displays them in the
-// fashion requested
+// fashion requested. The time spent on each check is recorded in timings.
+// processResults returns the number of successful test results, the failed
+// test results, and the paths of all tests which produced results.
 func processResults(results chan *tester.TestResult, testsDone chan struct{},
-	numTestJobs int) (int, []*tester.TestResult) {
+	numTestJobs int, writers []report.Writer,
+	timings *Timings) (int, []*tester.TestResult, []string) {
 
 	numGoodTests := 0
 	var badTests []*tester.TestResult
+	var tests []string
+	seen := make(map[string]bool)
+	handleResult := func(r *tester.TestResult) {
+		if !seen[r.Path] {
+			seen[r.Path] = true
+			tests = append(tests, r.Path)
+		}
+		if r.Success {
+			numGoodTests++
+		} else {
+			badTests = append(badTests, r)
+		}
+		printResult(r)
+		timings.addResult(r)
+		for _, w := range writers {
+			if err := w.Add(r); err != nil {
+				log.Printf("Failed to write test result: %v", err)
+			}
+		}
+	}
+
 	t := 0
 	for t < numTestJobs {
 		select {
 		case r := <-results:
-			if r.Success {
-				numGoodTests++
-			} else {
-				badTests = append(badTests, r)
-			}
-			printResult(r)
+			handleResult(r)
 		case <-testsDone:
 			t++
 		}
@@ -290,25 +633,22 @@ Done:
 	for {
 		select {
 		case r := <-results:
-			if r.Success {
-				numGoodTests++
-			} else {
-				badTests = append(badTests, r)
-			}
-			printResult(r)
+			handleResult(r)
 		default:
 			break Done
 		}
 	}
 
-	return numGoodTests, badTests
+	return numGoodTests, badTests, tests
 }
 
 // printResults displays the outcome for a single test result
 func printResult(result *tester.TestResult) {
 
 	testName := filepath.Base(result.Path)
-	if result.Success {
+	if result.Interrupted {
+		fmt.Printf("%-43s ::   %-25s ***[INTERRUPTED]***\n", testName, result.TestName)
+	} else if result.Success {
 		fmt.Printf("%-43s ::   %-25s       [SUCCESS]\n", testName, result.TestName)
 	} else {
 		fmt.Printf("%-43s ::   %-25s    ***[FAILURE]***\n", testName, result.TestName)
@@ -0,0 +1,99 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/mcellteam/nutmeg/src/misc"
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// FailedTests keeps track of the names of all failed checks of each test
+// keyed by the path of the test directory. It is persisted between nutmeg
+// runs to allow rerunning only the tests which failed previously.
+type FailedTests map[string][]string
+
+// ReadFailedTests reads the failed tests recorded in the state file at path.
+// If there is no state file yet, an empty set of failed tests is returned.
+func ReadFailedTests(path string) (FailedTests, error) {
+	failed := make(FailedTests)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return failed, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &failed); err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// Write writes the failed tests to the state file at path
+func (f FailedTests) Write(path string) error {
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Update replaces the recorded failures of the tests that produced results,
+// e.g., the Tests of a Summary, with the failures in badTests. The failures of
+// tests which weren't run, for instance since nutmeg was interrupted before
+// they were scheduled, are kept.
+func (f FailedTests) Update(tests []string, badTests []*tester.TestResult) {
+	for _, t := range tests {
+		delete(f, t)
+	}
+	for _, r := range badTests {
+		if !misc.ContainsString(f[r.Path], r.TestName) {
+			f[r.Path] = append(f[r.Path], r.TestName)
+		}
+	}
+}
+
+// Select returns the tests in tests which have recorded failures
+func (f FailedTests) Select(tests []string) []string {
+	var selection []string
+	for _, t := range tests {
+		if _, ok := f[t]; ok {
+			selection = append(selection, t)
+		}
+	}
+	return selection
+}
+
+// Paths returns the sorted list of paths of all tests with recorded failures
+func (f FailedTests) Paths() []string {
+	var paths []string
+	for p := range f {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Fixed returns the tests in tests which have recorded failures but none of
+// the provided badTests
+func (f FailedTests) Fixed(tests []string, badTests []*tester.TestResult) []string {
+	stillFailing := make(map[string]bool)
+	for _, r := range badTests {
+		stillFailing[r.Path] = true
+	}
+
+	var fixed []string
+	for _, t := range f.Select(tests) {
+		if !stillFailing[t] {
+			fixed = append(fixed, t)
+		}
+	}
+	return fixed
+}
@@ -0,0 +1,173 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/misc"
+	"github.com/mcellteam/nutmeg/src/tester"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// Version is the nutmeg version recorded in run manifests. It is set at build
+// time via -ldflags "-X github.com/mcellteam/nutmeg/src/engine.Version=...".
+// If unset, the VCS revision nutmeg was built from is used instead.
+var Version string
+
+// name of the run manifest within the output directory of each test
+const manifestFileName = "manifest.json"
+
+// maximum time allowed for determining the MCell version
+const versionTimeout = 10 * time.Second
+
+// Manifest records the provenance of all simulation runs of a test
+type Manifest struct {
+	NutmegVersion string                      `json:"nutmegVersion"`
+	MasterSeed    int64                       `json:"masterSeed"`
+	MCell         MCellInfo                   `json:"mcell"`
+	Test          *tomlParser.TestDescription `json:"test"` // resolved test description
+	Runs          []Invocation                `json:"runs"`
+}
+
+// MCellInfo describes the MCell executable used for running the simulations
+type MCellInfo struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+	Version string `json:"version"` // output of mcell -version
+}
+
+// Invocation describes a single MCell run of a test
+type Invocation struct {
+	MdlFile      string    `json:"mdlFile"`
+	Seed         int       `json:"seed"`
+	Args         []string  `json:"args"` // full commandline including wrapper
+	Dir          string    `json:"dir"`  // working directory
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Success      bool      `json:"success"`
+	ExitCode     int       `json:"exitCode"`
+	ExitMessage  string    `json:"exitMessage,omitempty"`
+	Signal       string    `json:"signal,omitempty"`
+	CoreDumped   bool      `json:"coreDumped,omitempty"`
+	TimedOut     bool      `json:"timedOut,omitempty"`
+	Interrupted  bool      `json:"interrupted,omitempty"`
+	HarnessError bool      `json:"harnessError,omitempty"`
+	Cached       bool      `json:"cached,omitempty"`
+}
+
+// newMCellInfo determines the checksum and version of the mcell executable
+// at mcellPath. Failures are recorded in place of the checksum or version.
+func newMCellInfo(mcellPath string) MCellInfo {
+	info := MCellInfo{Path: mcellPath}
+
+	if f, err := os.Open(mcellPath); err != nil {
+		info.SHA256 = "unknown: " + err.Error()
+	} else {
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			info.SHA256 = "unknown: " + err.Error()
+		} else {
+			info.SHA256 = hex.EncodeToString(h.Sum(nil))
+		}
+		f.Close()
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(mcellPath, "-version")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := misc.RunCommand(cmd, versionTimeout, nil); err != nil && out.Len() == 0 {
+		info.Version = "unknown: " + err.Error()
+	} else {
+		info.Version = strings.TrimSpace(out.String())
+	}
+	return info
+}
+
+// nutmegVersion returns the version of nutmeg
+func nutmegVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// writeManifest writes the manifest of the simulation runs of test to the
+// test's output directory
+func writeManifest(mcell MCellInfo, masterSeed int64, test *tester.TestData) error {
+	m := Manifest{NutmegVersion: nutmegVersion(), MasterSeed: masterSeed, MCell: mcell,
+		Test: test.TestDescription}
+	for _, s := range test.SimStatus {
+		m.Runs = append(m.Runs, Invocation{MdlFile: s.MdlFile, Seed: s.Seed,
+			Args: s.Args, Dir: s.Dir, Start: s.Start, End: s.Start.Add(s.Duration),
+			Success: s.Success, ExitCode: s.ExitCode, ExitMessage: s.ExitMessage,
+			Signal: s.Signal, CoreDumped: s.CoreDumped, TimedOut: s.TimedOut,
+			Interrupted: s.Interrupted, HarnessError: s.HarnessError, Cached: s.Cached})
+	}
+
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(file.GetOutputDir(test.Path), manifestFileName)
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// readManifest reads the run manifest from the output directory of the test
+// at testDir
+func readManifest(testDir string) (*Manifest, error) {
+	path := filepath.Join(file.GetOutputDir(testDir), manifestFileName)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ManifestMasterSeed returns the master seed recorded in the run manifest of
+// the test at testDir, i.e., the master seed of the run which produced the
+// test's current output
+func ManifestMasterSeed(testDir string) (int64, error) {
+	m, err := readManifest(testDir)
+	if err != nil {
+		return 0, err
+	}
+	return m.MasterSeed, nil
+}
+
+// runStatus reconstructs the status of the recorded MCell run
+func (inv *Invocation) runStatus() tester.RunStatus {
+	return tester.RunStatus{Success: inv.Success, ExitMessage: inv.ExitMessage,
+		ExitCode: inv.ExitCode, TimedOut: inv.TimedOut, Interrupted: inv.Interrupted,
+		HarnessError: inv.HarnessError, Cached: inv.Cached, Signal: inv.Signal,
+		CoreDumped: inv.CoreDumped, MdlFile: inv.MdlFile, Seed: inv.Seed,
+		Args: inv.Args, Dir: inv.Dir, Start: inv.Start,
+		Duration: inv.End.Sub(inv.Start)}
+}
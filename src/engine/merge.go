@@ -0,0 +1,126 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/mcellteam/nutmeg/src/report"
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// MergedResult combines the results of a single check of a test across
+// several result files
+type MergedResult struct {
+	*tester.TestResult          // representative result, failed if the check failed anywhere
+	PassedIn           []string // result files in which the check passed
+	FailedIn           []string // result files in which the check failed
+	InterruptedIn      []string // result files in which the test was interrupted
+}
+
+// Conflict checks if the check passed in one result file and failed in
+// another
+func (m *MergedResult) Conflict() bool {
+	return len(m.PassedIn) > 0 && len(m.FailedIn) > 0
+}
+
+// mergeKey identifies a check across result files. Tests are identified by
+// their name since the path of the test directory may differ between
+// machines.
+type mergeKey struct {
+	test       string
+	checkIndex int
+	testType   string
+}
+
+// MergeResults reads the JSON Lines result files at paths and combines
+// the results of each check of each test into a single result. The failed
+// result of a check takes precedence over passing ones. Since interrupted
+// tests are reported as a single result instead of per check, interrupted
+// results are merged per test and only used if the test has no other
+// results. The merged results are sorted by test name and check index.
+// Result files which are given more than once or contain several results for
+// the same check are rejected since they would skew the merged outcome.
+func MergeResults(paths []string) ([]*MergedResult, error) {
+	merged := make(map[mergeKey]*MergedResult)
+	interrupted := make(map[string]*MergedResult)
+	mergedPaths := make(map[string]bool)
+	for _, path := range paths {
+		if mergedPaths[filepath.Clean(path)] {
+			return nil, fmt.Errorf("result file %s was given more than once", path)
+		}
+		mergedPaths[filepath.Clean(path)] = true
+
+		results, err := report.ReadJSON(path)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[mergeKey]bool)
+		for _, r := range results {
+			name := filepath.Base(r.Path)
+			if r.Interrupted {
+				m, ok := interrupted[name]
+				if !ok {
+					m = &MergedResult{TestResult: r}
+					interrupted[name] = m
+				}
+				m.InterruptedIn = append(m.InterruptedIn, path)
+				continue
+			}
+
+			key := mergeKey{name, r.CheckIndex, r.TestName}
+			if seen[key] {
+				return nil, fmt.Errorf("%s contains several results for check %d "+
+					"(%s) of test %s", path, r.CheckIndex, r.TestName, name)
+			}
+			seen[key] = true
+
+			m, ok := merged[key]
+			if !ok {
+				m = &MergedResult{TestResult: r}
+				merged[key] = m
+			}
+
+			switch {
+			case r.Success:
+				if len(m.PassedIn) == 0 && len(m.FailedIn) == 0 {
+					m.TestResult = r
+				}
+				m.PassedIn = append(m.PassedIn, path)
+			default:
+				if len(m.FailedIn) == 0 {
+					m.TestResult = r
+				}
+				m.FailedIn = append(m.FailedIn, path)
+			}
+		}
+	}
+
+	var results []*MergedResult
+	completed := make(map[string]bool)
+	for key, m := range merged {
+		completed[key.test] = true
+		results = append(results, m)
+	}
+	for name, m := range interrupted {
+		if !completed[name] {
+			results = append(results, m)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		ti, tj := filepath.Base(results[i].Path), filepath.Base(results[j].Path)
+		if ti != tj {
+			return ti < tj
+		}
+		if results[i].CheckIndex != results[j].CheckIndex {
+			return results[i].CheckIndex < results[j].CheckIndex
+		}
+		return results[i].TestName < results[j].TestName
+	})
+	return results, nil
+}
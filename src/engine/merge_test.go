@@ -0,0 +1,153 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mcellteam/nutmeg/src/report"
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// writeResults writes the provided results to the JSON Lines file name in
+// dir and returns its path
+func writeResults(t *testing.T, dir, name string, results ...*tester.TestResult) string {
+	path := filepath.Join(dir, name)
+	w, err := report.NewJSONWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if err := w.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// passed returns a passing result of check i of test
+func passed(test string, i int, testType string) *tester.TestResult {
+	return &tester.TestResult{Path: "/tests/" + test, Success: true,
+		TestName: testType, CheckIndex: i}
+}
+
+// failed returns a failing result of check i of test
+func failed(test string, i int, testType string) *tester.TestResult {
+	return &tester.TestResult{Path: "/tests/" + test, TestName: testType,
+		CheckIndex: i, ErrorMessage: "failed", Category: tester.CheckFailure}
+}
+
+// interrupted returns the result of an interrupted test
+func interrupted(test string) *tester.TestResult {
+	return &tester.TestResult{Path: "/tests/" + test, TestName: "interrupted",
+		CheckIndex: -1, Interrupted: true, Category: tester.HarnessError}
+}
+
+// mergeSummary describes a merged result for comparison
+type mergeSummary struct {
+	test        string
+	check       int
+	success     bool
+	interrupted bool
+	passedIn    int
+	failedIn    int
+	conflict    bool
+}
+
+// summarize returns the summaries of the merged results
+func summarize(merged []*MergedResult) []mergeSummary {
+	var summaries []mergeSummary
+	for _, m := range merged {
+		summaries = append(summaries, mergeSummary{filepath.Base(m.Path),
+			m.CheckIndex, m.Success, m.Interrupted, len(m.PassedIn), len(m.FailedIn),
+			m.Conflict()})
+	}
+	return summaries
+}
+
+func TestMergeResults(t *testing.T) {
+	dir := t.TempDir()
+	shard1 := writeResults(t, dir, "shard1.jsonl",
+		passed("b", 0, "CHECK_SUCCESS"), failed("b", 1, "COUNT_MINMAX"),
+		passed("a", 0, "CHECK_SUCCESS"), passed("a", 1, "ZERO_COUNTS"),
+		interrupted("c"))
+	shard2 := writeResults(t, dir, "shard2.jsonl",
+		passed("c", 0, "CHECK_SUCCESS"), passed("d", 0, "CHECK_SUCCESS"),
+		interrupted("e"))
+
+	merged, err := MergeResults([]string{shard1, shard2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mergeSummary{
+		{"a", 0, true, false, 1, 0, false},
+		{"a", 1, true, false, 1, 0, false},
+		{"b", 0, true, false, 1, 0, false},
+		{"b", 1, false, false, 0, 1, false},
+		{"c", 0, true, false, 1, 0, false},
+		{"d", 0, true, false, 1, 0, false},
+		{"e", -1, false, true, 0, 0, false},
+	}
+	if got := summarize(merged); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged results\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestMergeResultsConflicts(t *testing.T) {
+	dir := t.TempDir()
+	run1 := writeResults(t, dir, "run1.jsonl",
+		passed("a", 0, "CHECK_SUCCESS"), passed("a", 1, "COUNT_MINMAX"),
+		failed("b", 0, "CHECK_SUCCESS"))
+	run2 := writeResults(t, dir, "run2.jsonl",
+		passed("a", 0, "CHECK_SUCCESS"), failed("a", 1, "COUNT_MINMAX"),
+		failed("b", 0, "CHECK_SUCCESS"))
+
+	merged, err := MergeResults([]string{run1, run2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mergeSummary{
+		{"a", 0, true, false, 2, 0, false},
+		{"a", 1, false, false, 1, 1, true},
+		{"b", 0, false, false, 0, 2, false},
+	}
+	if got := summarize(merged); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged results\n got %v\nwant %v", got, want)
+	}
+	if p := merged[1].FailedIn; !reflect.DeepEqual(p, []string{run2}) {
+		t.Errorf("conflicting check failed in %v, want %v", p, []string{run2})
+	}
+}
+
+func TestMergeResultsErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := writeResults(t, dir, "good.jsonl", passed("a", 0, "CHECK_SUCCESS"))
+	duplicate := writeResults(t, dir, "duplicate.jsonl",
+		passed("a", 0, "CHECK_SUCCESS"), failed("a", 0, "CHECK_SUCCESS"))
+
+	cases := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"duplicate check", []string{good, duplicate}, "several results for check 0"},
+		{"duplicate file", []string{good, good}, "given more than once"},
+		{"missing file", []string{filepath.Join(dir, "missing.jsonl")}, "missing.jsonl"},
+	}
+	for _, c := range cases {
+		merged, err := MergeResults(c.paths)
+		if err == nil {
+			t.Errorf("%s: got %d results, want error", c.name, len(merged))
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want it to mention %q", c.name, err, c.want)
+		}
+	}
+}
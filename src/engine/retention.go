@@ -0,0 +1,188 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// RetentionPolicy determines which test output is kept after a run
+type RetentionPolicy int
+
+// available retention policies
+const (
+	KeepAll       RetentionPolicy = iota // keep the output of all tests
+	KeepFailed                           // delete the output of passing tests
+	ArchiveFailed                        // like KeepFailed but also archive failed output
+)
+
+// name of the failure summary within failed test archives
+const failureSummaryName = "failures.txt"
+
+// ParseRetentionPolicy converts the name of a retention policy (all, failed,
+// or archive) into a RetentionPolicy. An empty name selects KeepAll.
+func ParseRetentionPolicy(name string) (RetentionPolicy, error) {
+	switch name {
+	case "", "all":
+		return KeepAll, nil
+	case "failed":
+		return KeepFailed, nil
+	case "archive":
+		return ArchiveFailed, nil
+	}
+	return KeepAll, fmt.Errorf("unknown retention policy %s (expected all, failed, "+
+		"or archive)", name)
+}
+
+// ApplyRetention applies the retention policy to the output of the provided
+// tests which all need to have produced results. Tests not among badTests are
+// considered passed and their output is removed. For ArchiveFailed, the
+// output of failed tests together with a summary of their failures is packed
+// into a gzip compressed tar archive whose path is returned. Otherwise, the
+// returned path is empty.
+func ApplyRetention(policy RetentionPolicy, tests []string,
+	badTests []*tester.TestResult) (string, error) {
+
+	if policy == KeepAll {
+		return "", nil
+	}
+
+	failed := make(map[string]bool)
+	for _, t := range badTests {
+		failed[t.Path] = true
+	}
+	for _, t := range tests {
+		if failed[t] {
+			continue
+		}
+		if err := os.RemoveAll(file.GetOutputDir(t)); err != nil {
+			return "", err
+		}
+	}
+
+	if policy != ArchiveFailed || len(badTests) == 0 {
+		return "", nil
+	}
+	archivePath := fmt.Sprintf("nutmeg-failed-%s.tar.gz",
+		time.Now().Format("20060102-150405"))
+	if err := archiveFailedTests(archivePath, tests, failed, badTests); err != nil {
+		os.Remove(archivePath)
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// archiveFailedTests writes the output of all failed tests and a summary of
+// their failures to a gzip compressed tar archive at archivePath. The output
+// of each test is stored below a directory named after the test.
+func archiveFailedTests(archivePath string, tests []string, failed map[string]bool,
+	badTests []*tester.TestResult) error {
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	summary := failureSummary(badTests)
+	hdr := &tar.Header{Name: failureSummaryName, Mode: 0644,
+		Size: int64(len(summary)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(summary); err != nil {
+		return err
+	}
+
+	for _, t := range tests {
+		if !failed[t] {
+			continue
+		}
+		outputDir := file.GetOutputDir(t)
+		if ok, _ := file.Exists(outputDir); !ok {
+			continue
+		}
+		if err := addTree(tw, outputDir, filepath.Base(t)); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addTree adds all directories and regular files below dir to the archive
+// using prefix as the name of dir within the archive
+func addTree(tw *tar.Writer, dir, prefix string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+}
+
+// failureSummary describes all failed checks including the stderr output of
+// the corresponding simulations
+func failureSummary(badTests []*tester.TestResult) []byte {
+	var b bytes.Buffer
+	for _, t := range badTests {
+		fmt.Fprintf(&b, "**** %s :: %s (%s) ****\n", filepath.Base(t.Path),
+			t.TestName, t.Category)
+		fmt.Fprintf(&b, "path: %s\n", t.Path)
+		if t.NumSeeds > 0 {
+			fmt.Fprintf(&b, "seed: %d  seeds: %v\n", t.Seed, t.Seeds)
+		}
+		fmt.Fprintf(&b, "\n%s\n", t.ErrorMessage)
+		if t.StdErrContent != "" {
+			fmt.Fprintf(&b, "\nstderr:\n%s\n", t.StdErrContent)
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.Bytes()
+}
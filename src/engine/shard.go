@@ -0,0 +1,172 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Shard selects the slice of the tests run by one of several nutmeg
+// instances, e.g., on different CI machines. Shards are numbered 1 through
+// Count.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses a shard specification of the form i/n
+func ParseShard(spec string) (Shard, error) {
+	items := strings.Split(spec, "/")
+	if len(items) != 2 {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/n", spec)
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(items[0]))
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/n", spec)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(items[1]))
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/n", spec)
+	}
+	if count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("invalid shard %q, need 1 <= i <= n", spec)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// Select returns the tests of the shard in their original order. The
+// partition only depends on the names of the tests and the provided runtimes
+// so that all shards agree on it as long as they are given the same
+// selection of tests and runtimes. Without runtimes the tests are dealt out
+// round robin in order of their names. Otherwise, the slowest tests are
+// assigned first, each to the shard with the smallest total runtime so far.
+// Tests without a recorded runtime are assumed to take the average time.
+func (s Shard) Select(tests []string, runtimes Runtimes) []string {
+	names := make([]string, len(tests))
+	for i, t := range tests {
+		names[i] = filepath.Base(t)
+	}
+	order := make([]int, len(tests))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return names[order[i]] < names[order[j]]
+	})
+
+	shardOf := make([]int, len(tests))
+	if len(runtimes) == 0 {
+		for k, i := range order {
+			shardOf[i] = k % s.Count
+		}
+	} else {
+		cost := make([]float64, len(tests))
+		defaultCost := runtimes.average(names)
+		for i, name := range names {
+			if r, ok := runtimes[name]; ok {
+				cost[i] = r
+			} else {
+				cost[i] = defaultCost
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return cost[order[i]] > cost[order[j]]
+		})
+
+		load := make([]float64, s.Count)
+		for _, i := range order {
+			minShard := 0
+			for k := 1; k < s.Count; k++ {
+				if load[k] < load[minShard] {
+					minShard = k
+				}
+			}
+			shardOf[i] = minShard
+			load[minShard] += cost[i]
+		}
+	}
+
+	var selection []string
+	for i, t := range tests {
+		if shardOf[i] == s.Index-1 {
+			selection = append(selection, t)
+		}
+	}
+	return selection
+}
+
+// Runtimes keeps track of the wall-clock time in seconds spent on each test
+// keyed by the name of the test. It is persisted between nutmeg runs to
+// balance shards.
+type Runtimes map[string]float64
+
+// ReadRuntimes reads the runtimes recorded in the state file at path. If
+// there is no state file yet, an empty set of runtimes is returned.
+func ReadRuntimes(path string) (Runtimes, error) {
+	runtimes := make(Runtimes)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return runtimes, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &runtimes); err != nil {
+		return nil, err
+	}
+	return runtimes, nil
+}
+
+// Write writes the runtimes to the state file at path
+func (r Runtimes) Write(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Update records the runtimes of all tests in timings. Tests with cached or
+// interrupted simulation runs keep their previous runtime since their
+// timings are not representative.
+func (r Runtimes) Update(timings *Timings) {
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+	for path, timing := range timings.tests {
+		representative := len(timing.Runs) > 0
+		for _, run := range timing.Runs {
+			if run.Cached || run.Interrupted {
+				representative = false
+			}
+		}
+		if representative {
+			r[filepath.Base(path)] = timing.Total().Seconds()
+		}
+	}
+}
+
+// average returns the average runtime of the named tests with a recorded
+// runtime or 1 s if none of them has one
+func (r Runtimes) average(names []string) float64 {
+	var sum float64
+	var count int
+	for _, name := range names {
+		if t, ok := r[name]; ok {
+			sum += t
+			count++
+		}
+	}
+	if count == 0 {
+		return 1
+	}
+	return sum / float64(count)
+}
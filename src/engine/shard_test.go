@@ -0,0 +1,124 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	valid := []struct {
+		spec string
+		want Shard
+	}{
+		{"1/1", Shard{Index: 1, Count: 1}},
+		{"1/3", Shard{Index: 1, Count: 3}},
+		{"3/3", Shard{Index: 3, Count: 3}},
+		{" 2 / 4 ", Shard{Index: 2, Count: 4}},
+	}
+	for _, tt := range valid {
+		got, err := ParseShard(tt.spec)
+		if err != nil {
+			t.Errorf("ParseShard(%q) failed: %v", tt.spec, err)
+		} else if got != tt.want {
+			t.Errorf("ParseShard(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	invalid := []string{"0/3", "4/3", "-1/3", "1/0", "a/b", "1/b", "a/3", "1",
+		"1/2/3", ""}
+	for _, spec := range invalid {
+		if got, err := ParseShard(spec); err == nil {
+			t.Errorf("ParseShard(%q) = %+v, want error", spec, got)
+		}
+	}
+}
+
+// selectAll returns the selections of all shards of count
+func selectAll(tests []string, count int, runtimes Runtimes) [][]string {
+	var selections [][]string
+	for i := 1; i <= count; i++ {
+		selections = append(selections, Shard{Index: i, Count: count}.Select(tests,
+			runtimes))
+	}
+	return selections
+}
+
+func TestShardSelectPartition(t *testing.T) {
+	var tests []string
+	for i := 0; i < 23; i++ {
+		tests = append(tests, fmt.Sprintf("/tests/test_%02d", (i*7)%23))
+	}
+	runtimes := Runtimes{"test_00": 30, "test_03": 12.5, "test_11": 0.2,
+		"test_17": 4, "test_22": 8}
+
+	for _, rt := range []Runtimes{nil, runtimes} {
+		for count := 1; count <= 25; count++ {
+			seen := make(map[string]int)
+			for _, selection := range selectAll(tests, count, rt) {
+				// each shard keeps the original order of the tests
+				pos := -1
+				for _, s := range selection {
+					seen[s]++
+					i := indexOf(tests, s)
+					if i <= pos {
+						t.Errorf("%d shards (runtimes %v): %s out of order", count,
+							rt != nil, s)
+					}
+					pos = i
+				}
+			}
+			for _, test := range tests {
+				if seen[test] != 1 {
+					t.Errorf("%d shards (runtimes %v): %s selected %d times", count,
+						rt != nil, test, seen[test])
+				}
+			}
+		}
+	}
+}
+
+func TestShardSelect(t *testing.T) {
+	tests := []string{"/tests/e", "/tests/d", "/tests/c", "/tests/b", "/tests/a"}
+	cases := []struct {
+		name     string
+		count    int
+		runtimes Runtimes
+		want     [][]string
+	}{
+		{"round robin by name", 2, nil, [][]string{
+			{"/tests/e", "/tests/c", "/tests/a"},
+			{"/tests/d", "/tests/b"}}},
+		{"slowest first", 2, Runtimes{"a": 10, "b": 1, "c": 1, "d": 1, "e": 7},
+			[][]string{
+				{"/tests/a"},
+				{"/tests/e", "/tests/d", "/tests/c", "/tests/b"}}},
+		{"average for unknown runtimes", 2, Runtimes{"a": 9, "b": 1},
+			[][]string{
+				{"/tests/e", "/tests/a"},
+				{"/tests/d", "/tests/c", "/tests/b"}}},
+		{"more shards than tests", 7, nil, [][]string{
+			{"/tests/a"}, {"/tests/b"}, {"/tests/c"}, {"/tests/d"}, {"/tests/e"},
+			nil, nil}},
+	}
+	for _, c := range cases {
+		got := selectAll(tests, c.count, c.runtimes)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// indexOf returns the index of s in items or -1 if it is missing
+func indexOf(items []string, s string) int {
+	for i, item := range items {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// Timings keeps track of the time spent running simulations and checks
+// for each test of a test run
+type Timings struct {
+	mutex sync.Mutex
+	tests map[string]*TestTiming
+}
+
+// TestTiming records the time spent on a single test
+type TestTiming struct {
+	Path         string             // path to test
+	Runs         []tester.RunStatus // status, including timings, of all mcell runs
+	SimTime      time.Duration      // wall-clock time of all mcell runs
+	SimCPUTime   time.Duration      // CPU time of all mcell runs
+	AnalysisTime time.Duration      // wall-clock time of all checks
+}
+
+// Total returns the overall wall-clock time spent on the test
+func (t *TestTiming) Total() time.Duration {
+	return t.SimTime + t.AnalysisTime
+}
+
+// SlowestRun returns the mcell run which took the longest
+func (t *TestTiming) SlowestRun() *tester.RunStatus {
+	var slowest *tester.RunStatus
+	for i := range t.Runs {
+		if slowest == nil || t.Runs[i].Duration > slowest.Duration {
+			slowest = &t.Runs[i]
+		}
+	}
+	return slowest
+}
+
+// newTimings creates an empty Timings struct
+func newTimings() *Timings {
+	return &Timings{tests: make(map[string]*TestTiming)}
+}
+
+// get returns the TestTiming for the test at path
+// NOTE: this function has to be called with the mutex locked
+func (t *Timings) get(path string) *TestTiming {
+	timing, ok := t.tests[path]
+	if !ok {
+		timing = &TestTiming{Path: path}
+		t.tests[path] = timing
+	}
+	return timing
+}
+
+// addRuns records the timings of the provided mcell runs for the test at path
+func (t *Timings) addRuns(path string, runs []tester.RunStatus) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	timing := t.get(path)
+	for _, r := range runs {
+		timing.Runs = append(timing.Runs, r)
+		timing.SimTime += r.Duration
+		timing.SimCPUTime += r.CPUTime
+	}
+}
+
+// addResult records the time spent on the check that produced result
+func (t *Timings) addResult(result *tester.TestResult) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.get(result.Path).AnalysisTime += result.Duration
+}
+
+// Slowest returns the n tests with the largest overall wall-clock time
+// sorted from slowest to fastest
+func (t *Timings) Slowest(n int) []*TestTiming {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var timings []*TestTiming
+	for _, timing := range t.tests {
+		timings = append(timings, timing)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].Total() > timings[j].Total()
+	})
+
+	if n < len(timings) {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+// Totals returns the total wall-clock and CPU time spent running mcell and
+// the total time spent analysing the simulation output
+func (t *Timings) Totals() (simTime, simCPUTime, analysisTime time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, timing := range t.tests {
+		simTime += timing.SimTime
+		simCPUTime += timing.SimCPUTime
+		analysisTime += timing.AnalysisTime
+	}
+	return simTime, simCPUTime, analysisTime
+}
@@ -8,6 +8,7 @@ package file
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -17,11 +18,27 @@ import (
 // name of output directory
 const outputDirName = "output"
 
+// root directory of out-of-tree test output and the test directory whose
+// layout it mirrors (see SetOutputRoot)
+var outputRoot, testRoot string
+
+// name of the per seed working directories of multi seed runs within the
+// output directory
+const seedDirFormat = "seed_%d"
+
 // Columns describes the content of a reaction data output file including a
-// column of time values and an arbitrary number of integer data columns
+// column of time values and an arbitrary number of numeric data columns
+// NOTE: The data is kept as float values since besides plain molecule counts
+// reaction data may contain concentrations or averaged values. For data
+// averaged across several seeds, Variances and StdErrs contain the variance
+// and standard error of the mean of each data item, otherwise they are nil.
+// If the data file has a header, Names contains the names of the data columns.
 type Columns struct {
-	Times  []float64
-	Counts [][]int
+	Times     []float64
+	Counts    [][]float64
+	Variances [][]float64
+	StdErrs   [][]float64
+	Names     []string
 }
 
 // StringColumns describes the content of a trigger data output file including a
@@ -57,15 +74,17 @@ func LoadData(dataPaths []string, haveHeader, averageData bool) ([]*Columns, err
 }
 
 // readAverageCounts parses all data in in the list of reaction data
-// filenames and computes and returns the average.
+// filenames and computes and returns the average. In addition, the variance
+// and standard error of the mean across the data sets is computed for each
+// data item.
 //
-// NOTE: this function assumes that the data files all have the same
+// NOTE: this function requires that the data files all have the same
 // shape, i.e. the same number of rows and columns
-//
-// NOTE: the average computation is done with integer arithmetic
 func readAverageCounts(fileNames []string, haveHeader bool) (*Columns, error) {
 
+	// the running mean and variance are computed via Welford's algorithm
 	var averageCols *Columns
+	var sumSquares [][]float64
 	for i, fileName := range fileNames {
 		col, err := ReadCounts(fileName, haveHeader)
 		if err != nil {
@@ -73,20 +92,43 @@ func readAverageCounts(fileNames []string, haveHeader bool) (*Columns, error) {
 		}
 
 		if i != 0 {
+			if len(col.Times) != len(averageCols.Times) ||
+				len(col.Counts) != len(averageCols.Counts) {
+				return nil, fmt.Errorf("%s: number of rows or columns differs from %s",
+					fileName, fileNames[0])
+			}
+
+			n := float64(i + 1)
 			for r := 0; r < len(averageCols.Times); r++ {
 				for c := 0; c < len(averageCols.Counts); c++ {
-					averageCols.Counts[c][r] += col.Counts[c][r]
+					x := col.Counts[c][r]
+					delta := x - averageCols.Counts[c][r]
+					averageCols.Counts[c][r] += delta / n
+					sumSquares[c][r] += delta * (x - averageCols.Counts[c][r])
 				}
 			}
 		} else { // set the average to the first data set
 			averageCols = col
+			sumSquares = make([][]float64, len(col.Counts))
+			for c := range sumSquares {
+				sumSquares[c] = make([]float64, len(col.Times))
+			}
 		}
 	}
 
-	numDataSets := len(fileNames)
-	for r := 0; r < len(averageCols.Times); r++ {
-		for c := 0; c < len(averageCols.Counts); c++ {
-			averageCols.Counts[c][r] = averageCols.Counts[c][r] / numDataSets
+	numDataSets := float64(len(fileNames))
+	averageCols.Variances = make([][]float64, len(averageCols.Counts))
+	averageCols.StdErrs = make([][]float64, len(averageCols.Counts))
+	for c := 0; c < len(averageCols.Counts); c++ {
+		averageCols.Variances[c] = make([]float64, len(averageCols.Times))
+		averageCols.StdErrs[c] = make([]float64, len(averageCols.Times))
+		if numDataSets < 2 {
+			continue
+		}
+		for r := 0; r < len(averageCols.Times); r++ {
+			variance := sumSquares[c][r] / (numDataSets - 1)
+			averageCols.Variances[c][r] = variance
+			averageCols.StdErrs[c][r] = math.Sqrt(variance / numDataSets)
 		}
 	}
 	return averageCols, nil
@@ -104,22 +146,23 @@ func ReadCounts(fileName string, haveHeader bool) (*Columns, error) {
 
 	scanner := bufio.NewScanner(file)
 
-	// throw away header
+	// parse column names from header
+	var cols Columns
 	if haveHeader {
 		scanner.Scan()
+		cols.Names = parseHeader(scanner.Text())
 	}
 
 	// read row by row
-	var cols Columns
 	for r := 0; scanner.Scan(); r++ {
 		lineItems := strings.Fields(scanner.Text())
 
 		if r == 0 {
 			cols.Times = make([]float64, 0)
 			n := len(lineItems) - 1
-			cols.Counts = make([][]int, n)
+			cols.Counts = make([][]float64, n)
 			for i := 0; i < n; i++ {
-				cols.Counts[i] = make([]int, 0)
+				cols.Counts[i] = make([]float64, 0)
 			}
 		}
 
@@ -130,7 +173,7 @@ func ReadCounts(fileName string, haveHeader bool) (*Columns, error) {
 		cols.Times = append(cols.Times, t)
 
 		for i, cs := range lineItems[1:] {
-			c, err := strconv.Atoi(cs)
+			c, err := strconv.ParseFloat(cs, 64)
 			if err != nil {
 				return nil, err
 			}
@@ -146,6 +189,55 @@ func ReadCounts(fileName string, haveHeader bool) (*Columns, error) {
 	return &cols, nil
 }
 
+// parseHeader extracts the data column names from the header of a reaction
+// data file. MCell headers are of the form
+//
+//	# Seconds name1 name2 ...
+//
+// with an optional leading comment character. The name of the time column
+// is dropped.
+func parseHeader(header string) []string {
+	items := strings.Fields(strings.TrimLeft(strings.TrimSpace(header), "#"))
+	if len(items) == 0 {
+		return nil
+	}
+	return items[1:]
+}
+
+// SelectColumns returns the data columns with the given names in the
+// requested order. The column names are determined from the header of the
+// data file.
+func (c *Columns) SelectColumns(names []string) (*Columns, error) {
+	if c.Names == nil {
+		return nil, fmt.Errorf("column selection requires a data file with header")
+	}
+	if len(c.Names) != len(c.Counts) {
+		return nil, fmt.Errorf("header lists %d column names but data has %d columns",
+			len(c.Names), len(c.Counts))
+	}
+
+	selection := &Columns{Times: c.Times, Names: names}
+	for _, name := range names {
+		id := -1
+		for i, n := range c.Names {
+			if n == name {
+				id = i
+				break
+			}
+		}
+		if id < 0 {
+			return nil, fmt.Errorf("unknown data column %s", name)
+		}
+
+		selection.Counts = append(selection.Counts, c.Counts[id])
+		if c.Variances != nil {
+			selection.Variances = append(selection.Variances, c.Variances[id])
+			selection.StdErrs = append(selection.StdErrs, c.StdErrs[id])
+		}
+	}
+	return selection, nil
+}
+
 // LoadStringData reads all the reaction data columns as strings. The
 // string data loader is used for analyzing trigger data since this
 // typically contains a mix of integer and float data
@@ -214,33 +306,26 @@ func readStringCounts(fileName string, haveHeader bool) (*StringColumns, error)
 
 // GetDataPaths returns a list of all reaction data files names that were
 // generated as part of this run (at least one but could be many for multi
-// seed runs)
-func GetDataPaths(path, dataFile string, seed, numSeeds int) ([]string, error) {
+// seed runs). A single format specifier in dataFile is replaced by each of
+// the provided seeds. For multi seed runs the data files are located in the
+// working directory of each seed (see GetRunDir).
+func GetDataPaths(path, dataFile string, seeds []int) ([]string, error) {
 
 	var dataPaths []string
-	dataDir := GetOutputDir(path)
 
 	// check if data file has a single format specifier
 	count := strings.Count(dataFile, "%")
+	if count > 1 {
+		return nil, fmt.Errorf("datafile has too many format specifiers")
+	}
 
-	switch count {
-	case 0:
-		filePath := filepath.Join(dataDir, dataFile)
-		dataPaths = append(dataPaths, filePath)
-	case 1:
-		if numSeeds == 1 {
-			fileName := fmt.Sprintf(dataFile, seed)
-			filePath := filepath.Join(dataDir, fileName)
-			dataPaths = append(dataPaths, filePath)
-		} else {
-			for i := 1; i < numSeeds+1; i++ {
-				fileName := fmt.Sprintf(dataFile, i)
-				filePath := filepath.Join(dataDir, fileName)
-				dataPaths = append(dataPaths, filePath)
-			}
+	for _, seed := range seeds {
+		fileName := dataFile
+		if count == 1 {
+			fileName = fmt.Sprintf(dataFile, seed)
 		}
-	default:
-		return nil, fmt.Errorf("datafile has too many format specifiers")
+		filePath := filepath.Join(GetRunDir(path, seed, len(seeds)), fileName)
+		dataPaths = append(dataPaths, filePath)
 	}
 
 	// expand all "*" glob patterns if present
@@ -268,9 +353,50 @@ func GetDataPaths(path, dataFile string, seed, numSeeds int) ([]string, error) {
 }
 
 // GetOutputDir returns the path in which the output for the testcase at path
-// is located
+// is located. The output directory is never the test directory itself since
+// it is removed when cleaning up test output.
 func GetOutputDir(testPath string) string {
-	return filepath.Join(testPath, outputDirName)
+	if outputRoot == "" {
+		return filepath.Join(testPath, outputDirName)
+	}
+
+	rel, err := filepath.Rel(testRoot, testPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(testPath)
+	}
+	outputDir := filepath.Join(outputRoot, rel)
+	if outputDir == filepath.Clean(testPath) {
+		outputDir = filepath.Join(outputDir, outputDirName)
+	}
+	return outputDir
+}
+
+// SetOutputRoot places the output of all tests below root instead of within
+// the test directories. The output directories below root mirror the layout
+// of the tests within testDir. An empty root restores the default layout.
+// Since test output is removed during cleanup, root must neither be testDir
+// nor lie within it.
+func SetOutputRoot(root, testDir string) error {
+	if root != "" {
+		rel, err := filepath.Rel(testDir, root)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s lies within the test directory %s", root, testDir)
+		}
+	}
+	outputRoot = root
+	testRoot = testDir
+	return nil
+}
+
+// GetRunDir returns the working directory of the simulation run with the
+// given seed of the testcase at testPath. Single seed runs work directly in
+// the output directory whereas each seed of a multi seed run has its own
+// subdirectory so concurrent seeds don't clobber each other's output.
+func GetRunDir(testPath string, seed, numSeeds int) string {
+	if numSeeds <= 1 {
+		return GetOutputDir(testPath)
+	}
+	return filepath.Join(GetOutputDir(testPath), fmt.Sprintf(seedDirFormat, seed))
 }
 
 // IsEmpty checks that the given file exists and is empty
@@ -0,0 +1,50 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// FindCoreFile returns the path of the core file dumped into dir by the
+// process with the given pid or an empty string if there is none. Core files
+// named either core or core.<pid> are recognized.
+func FindCoreFile(dir string, pid int) string {
+	for _, name := range []string{fmt.Sprintf("core.%d", pid), "core"} {
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Backtrace captures the backtraces of all threads recorded in the core file
+// of executable using gdb or, if gdb is not installed, lldb. The debugger is
+// killed if it doesn't finish within timeout.
+func Backtrace(executable, coreFile string, timeout time.Duration) (string, error) {
+	var cmd *exec.Cmd
+	if gdb, err := exec.LookPath("gdb"); err == nil {
+		cmd = exec.Command(gdb, "-batch", "-ex", "thread apply all bt", executable,
+			coreFile)
+	} else if lldb, err := exec.LookPath("lldb"); err == nil {
+		cmd = exec.Command(lldb, "--batch", "-c", coreFile, executable, "-o",
+			"thread backtrace all")
+	} else {
+		return "", errors.New("neither gdb nor lldb is installed")
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := RunCommand(cmd, timeout, nil)
+	return out.String(), err
+}
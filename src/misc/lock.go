@@ -0,0 +1,90 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// name of the session lock file
+const lockFileName = "nutmeg.lock"
+
+// Lock is a session lock preventing concurrent nutmeg sessions from writing
+// into the same test output
+type Lock struct {
+	path string
+}
+
+// AcquireLock acquires the session lock for the test output below dir. The
+// lock is recorded in a lock file containing the host name and pid of the
+// owning nutmeg session. Locks left behind by sessions on the same host which
+// are no longer running are taken over.
+func AcquireLock(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	path := filepath.Join(dir, lockFileName)
+	owner := fmt.Sprintf("%s %d\n", host, os.Getpid())
+
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path}, nil
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+
+		lockHost, pid, err := readLock(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock file %s: %v", path, err)
+		}
+		if lockHost != host || processExists(pid) {
+			return nil, fmt.Errorf("test output in %s is in use by another nutmeg "+
+				"session (pid %d on %s); remove %s if that session is no longer running",
+				dir, pid, lockHost, path)
+		}
+		// stale lock
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Release releases the session lock
+func (l *Lock) Release() error {
+	return os.Remove(l.path)
+}
+
+// readLock returns the host name and pid of the session owning the lock file
+// at path
+func readLock(path string) (string, int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	items := strings.Fields(string(content))
+	if len(items) != 2 {
+		return "", 0, fmt.Errorf("malformed lock file")
+	}
+	pid, err := strconv.Atoi(items[1])
+	if err != nil {
+		return "", 0, err
+	}
+	return items[0], pid, nil
+}
@@ -7,22 +7,23 @@ package misc
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
+	"math"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 
+	"github.com/mcellteam/nutmeg/src/file"
 	"github.com/mcellteam/nutmeg/src/tomlParser"
 )
 
 // CleanOutput removes all files leftover from a previous test run
 func CleanOutput(tests []string) error {
 	for _, path := range tests {
-		outputPath := filepath.Join(path, "output")
+		outputPath := file.GetOutputDir(path)
 		if err := os.RemoveAll(outputPath); err != nil {
 			return err
 		}
@@ -30,14 +31,27 @@ func CleanOutput(tests []string) error {
 	return nil
 }
 
-// WriteCmdLine writes the commandline with which MCell was called
-// to the output directory
-func WriteCmdLine(mcellPath string, outputDir string, argList []string) error {
-	cmdlineArgs := append([]string{mcellPath}, argList...)
-	cmdlineArgs = append(cmdlineArgs, "\n")
-	cmdline := strings.Join(cmdlineArgs, " ")
-	cmdlinePath := filepath.Join(outputDir, "commandline.txt")
-	return ioutil.WriteFile(cmdlinePath, []byte(cmdline), 0644)
+// CopyFile copies the file at src to dest
+func CopyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
 
 // DetermineExitCode tries to figure out the exit code of a failed command
@@ -53,6 +67,42 @@ func DetermineExitCode(err error) (int, error) {
 	return 0, err
 }
 
+// signalNames maps the signals commonly terminating MCell to their names
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGTRAP: "SIGTRAP",
+}
+
+// DetermineSignal checks if a failed command execution via
+// exec.Command(...).Run() was terminated by a signal. It returns a
+// description of the signal, e.g., "SIGSEGV (segmentation fault)", and
+// whether a core was dumped. If the command was not terminated by a signal
+// the returned description is empty.
+func DetermineSignal(err error) (string, bool) {
+	if e, ok := err.(*exec.ExitError); ok {
+		if s, ok := e.Sys().(syscall.WaitStatus); ok && s.Signaled() {
+			sig := s.Signal()
+			name, ok := signalNames[sig]
+			if !ok {
+				name = fmt.Sprintf("signal %d", int(sig))
+			}
+			return fmt.Sprintf("%s (%v)", name, sig), s.CoreDump()
+		}
+	}
+	return "", false
+}
+
 // ContainsString checks if a given string is part of the provided string slice
 // and returns true if yes and false otherwise
 func ContainsString(ss []string, item string) bool {
@@ -167,6 +217,17 @@ func UnsetTrackers(s int, xs ...*int) {
 
 // below are some useful math functions
 
+// floatTolerance is the relative tolerance used when comparing floating
+// point values for equality
+const floatTolerance = 1e-9
+
+// ApproxEqual checks if a and b differ by at most absTol. To account for
+// rounding of floating point data, a and b are allowed to differ by an
+// additional small relative tolerance.
+func ApproxEqual(a, b, absTol float64) bool {
+	return math.Abs(a-b) <= absTol+floatTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
 // Abs returns the absolute value of integer i
 func Abs(i int) int {
 	var isize uint = strconv.IntSize - 1
@@ -0,0 +1,65 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+)
+
+// ErrTimedOut is returned by RunCommand if the command was killed since it
+// exceeded its timeout
+var ErrTimedOut = errors.New("command timed out")
+
+// ErrInterrupted is returned by RunCommand if the command was killed since
+// it was cancelled
+var ErrInterrupted = errors.New("command interrupted")
+
+// RunCommand starts cmd and waits for it to finish. If cmd does not finish
+// within timeout or cancel is closed it is killed together with all
+// processes it spawned and ErrTimedOut or ErrInterrupted is returned,
+// respectively. A timeout of zero or less disables the timeout.
+func RunCommand(cmd *exec.Cmd, timeout time.Duration, cancel <-chan struct{}) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		killProcessGroup(cmd)
+		<-done
+		return ErrTimedOut
+	case <-cancel:
+		killProcessGroup(cmd)
+		<-done
+		return ErrInterrupted
+	}
+}
+
+// IsClosed checks if the provided signalling channel has been closed
+func IsClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
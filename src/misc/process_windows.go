@@ -0,0 +1,35 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package misc
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started cmd
+// NOTE: On windows only the process itself is killed but not any of its
+// children
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
+
+// processExists checks if a process with the given pid is running
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
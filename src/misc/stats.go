@@ -0,0 +1,123 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"math"
+)
+
+// MeanAndStdErr returns the mean and the standard error of the mean of the
+// provided values. The standard error is computed from the sample standard
+// deviation and is zero if there are less than two values.
+func MeanAndStdErr(values []float64) (float64, float64) {
+	n := float64(len(values))
+	if n == 0 {
+		return 0, 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+
+	if n < 2 {
+		return mean, 0
+	}
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= (n - 1)
+	return mean, math.Sqrt(variance / n)
+}
+
+// NormalPValue returns the two-sided p-value of the standard normal test
+// statistic z
+func NormalPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// StudentTPValue returns the two-sided p-value of the test statistic t
+// of Student's t-distribution with df degrees of freedom
+func StudentTPValue(t, df float64) float64 {
+	return regIncBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regIncBeta computes the regularized incomplete beta function I_x(a, b)
+// NOTE: This follows the continued fraction approach of Numerical Recipes
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	} else if x >= 1 {
+		return 1
+	}
+
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// the continued fraction converges rapidly for x < (a+1)/(a+b+2),
+	// otherwise we use the symmetry relation I_x(a, b) = 1 - I_1-x(b, a)
+	if x < (a+1)/(a+b+2) {
+		return front * betaContFrac(a, b, x) / a
+	}
+	return 1 - front*betaContFrac(b, a, 1-x)/b
+}
+
+// betaContFrac evaluates the continued fraction of the incomplete beta
+// function via the modified Lentz method
+func betaContFrac(a, b, x float64) float64 {
+	const maxIter = 300
+	const eps = 1e-15
+	const tiny = 1e-300
+
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+
+		// even step
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// odd step
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return h
+}
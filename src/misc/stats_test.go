@@ -0,0 +1,61 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStudentTPValue(t *testing.T) {
+	tests := []struct {
+		t, df float64
+		want  float64
+		tol   float64
+	}{
+		{2.228, 10, 0.05, 5e-5},                       // tabulated critical value
+		{2.086, 20, 0.05, 5e-5},                       // tabulated critical value
+		{-2.228, 10, 0.05, 5e-5},                      // two-sided
+		{0, 10, 1, 1e-12},                             // no deviation
+		{0, 1, 1, 1e-12},                              // no deviation
+		{1, 1, 0.5, 1e-12},                            // Cauchy: 1 - 2/pi atan(t)
+		{12.706, 1, 0.05, 5e-5},                       // tabulated critical value
+		{2, 2, 1 - 2/math.Sqrt(6), 1e-12},             // closed form for df = 2
+		{1.959964, 1e6, NormalPValue(1.959964), 1e-6}, // normal limit
+		{1e6, 10, 0, 1e-12},                           // very large t
+		{-1e6, 1, 2 / (math.Pi * 1e6), 1e-12},         // very large t
+	}
+	for _, tt := range tests {
+		got := StudentTPValue(tt.t, tt.df)
+		if math.IsNaN(got) || math.Abs(got-tt.want) > tt.tol {
+			t.Errorf("StudentTPValue(%g, %g) = %g, want %g", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestRegIncBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{1, 1, 0.3, 0.3},                  // uniform distribution
+		{2, 1, 0.5, 0.25},                 // I_x(a, 1) = x^a
+		{1, 3, 0.5, 1 - 0.125},            // I_x(1, b) = 1 - (1-x)^b
+		{2, 3, 0.4, 0.5248},               // polynomial closed form
+		{5, 5, 0.5, 0.5},                  // symmetry
+		{0.5, 0.5, 0.25, 1.0 / 3},         // arcsine distribution
+		{2, 3, 0, 0},                      // lower bound
+		{2, 3, 1, 1},                      // upper bound
+		{2, 3, -0.1, 0},                   // below support
+		{30, 1, 0.99, math.Pow(0.99, 30)}, // I_x(a, 1) = x^a
+	}
+	for _, tt := range tests {
+		got := regIncBeta(tt.a, tt.b, tt.x)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("regIncBeta(%g, %g, %g) = %.12g, want %.12g", tt.a, tt.b, tt.x,
+				got, tt.want)
+		}
+	}
+}
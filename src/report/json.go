@@ -0,0 +1,115 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// JSONWriter writes test results as a JSON Lines stream, i.e., one JSON
+// record per line and test result. Each record is written as soon as the
+// corresponding result is added.
+type JSONWriter struct {
+	out *os.File
+	enc *json.Encoder
+}
+
+// jsonRecord is the JSON representation of a single test result
+type jsonRecord struct {
+	Test         string  `json:"test"`
+	Path         string  `json:"path"`
+	CheckIndex   int     `json:"checkIndex"`
+	TestType     string  `json:"testType"`
+	Description  string  `json:"description,omitempty"`
+	Seed         int     `json:"seed"`
+	NumSeeds     int     `json:"numSeeds"`
+	Seeds        []int   `json:"seeds,omitempty"`
+	Success      bool    `json:"success"`
+	Interrupted  bool    `json:"interrupted,omitempty"`
+	Category     string  `json:"category,omitempty"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+	Duration     float64 `json:"duration"` // wall-clock time in seconds
+}
+
+// NewJSONWriter returns a JSONWriter writing to the file at path
+func NewJSONWriter(path string) (*JSONWriter, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONWriter{out: out, enc: json.NewEncoder(out)}, nil
+}
+
+// Add writes the JSON record for a single test result
+func (j *JSONWriter) Add(r *tester.TestResult) error {
+	record := jsonRecord{Test: testName(r), Path: r.Path,
+		CheckIndex: r.CheckIndex, TestType: r.TestName, Description: r.Description,
+		Seed: r.Seed, NumSeeds: r.NumSeeds, Seeds: r.Seeds, Success: r.Success,
+		Interrupted: r.Interrupted, ErrorMessage: r.ErrorMessage,
+		Duration: r.Duration.Seconds()}
+	if !r.Success {
+		record.Category = r.Category.String()
+	}
+	return j.enc.Encode(record)
+}
+
+// Close closes the underlying file
+func (j *JSONWriter) Close() error {
+	return j.out.Close()
+}
+
+// ReadJSON reads the test results from the JSON Lines file at path written
+// by a JSONWriter
+func ReadJSON(path string) ([]*tester.TestResult, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var results []*tester.TestResult
+	dec := json.NewDecoder(in)
+	for {
+		var record jsonRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, len(results)+1, err)
+		}
+		category, err := parseCategory(record.Category)
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, len(results)+1, err)
+		}
+		results = append(results, &tester.TestResult{Path: record.Path,
+			Success: record.Success, TestName: record.TestType,
+			ErrorMessage: record.ErrorMessage, CheckIndex: record.CheckIndex,
+			Description: record.Description, Seed: record.Seed,
+			NumSeeds: record.NumSeeds, Seeds: record.Seeds,
+			Interrupted: record.Interrupted, Category: category,
+			Duration: time.Duration(record.Duration * float64(time.Second))})
+	}
+	return results, nil
+}
+
+// parseCategory converts the name of a failure category back into the
+// failure category. An empty name denotes a successful test.
+func parseCategory(name string) (tester.FailureCategory, error) {
+	if name == "" {
+		return tester.NoFailure, nil
+	}
+	for _, c := range []tester.FailureCategory{tester.CheckFailure,
+		tester.SimulatorCrash, tester.HarnessError} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return tester.NoFailure, fmt.Errorf("unknown failure category %q", name)
+}
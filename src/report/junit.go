@@ -0,0 +1,157 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// JUnitWriter collects test results and writes them as a JUnit XML report.
+// Each test directory becomes a testsuite and each of its checks a testcase.
+// Since the report can only be assembled once all results are known it is
+// written when the JUnitWriter is closed.
+type JUnitWriter struct {
+	out     io.WriteCloser
+	results []*tester.TestResult
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite describes all checks of a single test
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase describes a single check
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+// junitFailure describes the failure of a check
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// junitSkipped describes a check which could not be run since nutmeg was
+// interrupted
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// NewJUnitWriter returns a JUnitWriter writing its report to the file at path
+func NewJUnitWriter(path string) (*JUnitWriter, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JUnitWriter{out: out}, nil
+}
+
+// Add records a single test result
+func (j *JUnitWriter) Add(result *tester.TestResult) error {
+	j.results = append(j.results, result)
+	return nil
+}
+
+// Close writes the JUnit XML report for all recorded results and closes
+// the underlying file
+func (j *JUnitWriter) Close() error {
+	err := j.write()
+	if cerr := j.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// write assembles the JUnit XML report and writes it out. Test suites are
+// sorted by name, the test cases within each suite are kept in the order in
+// which they were added.
+func (j *JUnitWriter) write() error {
+	suiteMap := make(map[string]*junitTestSuite)
+	suiteTimes := make(map[string]time.Duration)
+	var names []string
+	var report junitTestSuites
+	var totalTime time.Duration
+	for _, r := range j.results {
+		name := testName(r)
+		suite, ok := suiteMap[name]
+		if !ok {
+			suite = &junitTestSuite{Name: name}
+			suiteMap[name] = suite
+			names = append(names, name)
+		}
+
+		testCase := junitTestCase{Name: r.TestName, ClassName: name,
+			Time: formatSeconds(r.Duration)}
+		if r.Interrupted {
+			testCase.Skipped = &junitSkipped{Message: r.ErrorMessage}
+			suite.Skipped++
+			report.Skipped++
+		} else if !r.Success {
+			testCase.Failure = &junitFailure{Message: r.ErrorMessage,
+				Type: r.Category.String(), Content: r.ErrorMessage}
+			testCase.SystemErr = r.StdErrContent
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+		report.Tests++
+		suiteTimes[name] += r.Duration
+		totalTime += r.Duration
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		suite := suiteMap[name]
+		suite.Time = formatSeconds(suiteTimes[name])
+		report.Suites = append(report.Suites, *suite)
+	}
+	report.Time = formatSeconds(totalTime)
+
+	if _, err := io.WriteString(j.out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(j.out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(j.out, "\n")
+	return err
+}
+
+// formatSeconds converts a duration into the seconds representation used by
+// JUnit reports
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,26 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package report writes the results of a nutmeg test run in machine
+// readable formats for consumption by external tools
+package report
+
+import (
+	"path/filepath"
+
+	"github.com/mcellteam/nutmeg/src/tester"
+)
+
+// Writer is the interface implemented by all test result writers. Add is
+// called for each test result as it becomes available and Close once
+// all results have been added.
+type Writer interface {
+	Add(result *tester.TestResult) error
+	Close() error
+}
+
+// testName returns the name of the test a test result belongs to
+func testName(result *tester.TestResult) string {
+	return filepath.Base(result.Path)
+}
@@ -0,0 +1,137 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tester
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// DataKind describes which simulation output a check needs loaded from its
+// data file before it runs
+type DataKind int
+
+// available kinds of check data
+const (
+	NoData      DataKind = iota // check reads its input itself (if any)
+	NumericData                 // numeric data columns (only loaded if dataFile is set)
+	StringData                  // string data columns, e.g., trigger output
+)
+
+// Checker is the interface implemented by all checks. Checkers are
+// registered via Register under the testType used in test descriptions.
+type Checker interface {
+	// Data returns the kind of data the check needs loaded
+	Data() DataKind
+
+	// Options returns a pointer to a new struct holding the check specific
+	// options. The options of each test case are decoded into it from the
+	// test description before the check runs. Checks without specific
+	// options return nil.
+	Options() interface{}
+
+	// Check runs the check and returns an error describing the failure if
+	// the check fails. Failures are reported as check failures unless a
+	// *CheckError is returned.
+	Check(in *CheckInput) error
+}
+
+// CheckInput is the input of a single check
+type CheckInput struct {
+	Test       *TestData             // test whose simulation output is checked
+	Case       *tomlParser.TestCase  // test case with the common options
+	Options    interface{}           // check specific options as returned by Options
+	DataPaths  []string              // paths to the data file of each seed
+	Data       []*file.Columns       // data of each seed (NumericData only)
+	StringData []*file.StringColumns // data of each seed (StringData only)
+}
+
+// CheckError describes the failure of a check which is caused by a
+// particular simulation run or needs to be classified differently than a
+// plain check failure
+type CheckError struct {
+	Err      error
+	Category FailureCategory
+	Run      *RunStatus // simulation run causing the failure (nil if none)
+	Abort    bool       // skip the remaining checks of the test
+}
+
+// Error returns the message of the underlying error
+func (e *CheckError) Error() string {
+	return e.Err.Error()
+}
+
+var (
+	checkersMutex sync.RWMutex
+	checkers      = make(map[string]Checker)
+)
+
+// Register makes a check available under the given testType. It is
+// intended to be called from the init function of the package implementing
+// the check. Register panics if a check with the same testType already
+// exists.
+func Register(testType string, checker Checker) {
+	checkersMutex.Lock()
+	defer checkersMutex.Unlock()
+	if checker == nil {
+		panic("tester: Register checker is nil")
+	}
+	if _, dup := checkers[testType]; dup {
+		panic("tester: Register called twice for check " + testType)
+	}
+	checkers[testType] = checker
+}
+
+// Checkers returns the sorted list of testTypes of all registered checks
+func Checkers() []string {
+	checkersMutex.RLock()
+	defer checkersMutex.RUnlock()
+	var types []string
+	for t := range checkers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// lookupChecker returns the check registered for testType
+func lookupChecker(testType string) (Checker, error) {
+	checkersMutex.RLock()
+	defer checkersMutex.RUnlock()
+	checker, ok := checkers[testType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown test type: %s", testType)
+	}
+	return checker, nil
+}
+
+// checkFunc implements a Checker via a function
+type checkFunc struct {
+	data    DataKind
+	options func() interface{}
+	check   func(in *CheckInput) error
+}
+
+// Data returns the kind of data the check needs
+func (c *checkFunc) Data() DataKind {
+	return c.data
+}
+
+// Options returns a new struct for the check's options
+func (c *checkFunc) Options() interface{} {
+	if c.options == nil {
+		return nil
+	}
+	return c.options()
+}
+
+// Check runs the check
+func (c *checkFunc) Check(in *CheckInput) error {
+	return c.check(in)
+}
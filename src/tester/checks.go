@@ -0,0 +1,286 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tester
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mcellteam/nutmeg/src/file"
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// meanTestOptions are the options of COUNT_MEAN_TEST checks
+type meanTestOptions struct {
+	tomlParser.TestMeans
+	tomlParser.TestHypothesis
+}
+
+// countRatesOptions are the options of COUNT_RATES checks
+type countRatesOptions struct {
+	tomlParser.TestRates
+	tomlParser.TestMeans
+}
+
+// register the checks built into nutmeg
+func init() {
+	Register("CHECK_SUCCESS", &checkFunc{data: NoData, check: checkSuccess})
+	Register("CHECK_EXIT_CODE", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestExitCode{} },
+		check:   checkExitCode})
+	Register("CHECK_NONEMPTY_FILES", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestFileSizes{} },
+		check: func(in *CheckInput) error {
+			return checkFilesEmpty(in.Test, in.Options.(*tomlParser.TestFileSizes), false)
+		}})
+	Register("CHECK_EMPTY_FILES", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestFileSizes{} },
+		check: func(in *CheckInput) error {
+			return checkFilesEmpty(in.Test, in.Options.(*tomlParser.TestFileSizes), true)
+		}})
+	Register("CHECK_CHECKPOINT", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestCheckPoint{} },
+		check:   checkCheckPoints})
+	Register("CHECK_LEGACY_VOL_OUTPUT", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestLegacyVolOutput{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestLegacyVolOutput)
+			return forEachPath(in, func(p string) error { return checkLegacyVolOutput(p, c) })
+		}})
+	Register("CHECK_ASCII_VIZ_OUTPUT", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestASCIIVizOutput{} },
+		check: func(in *CheckInput) error {
+			return forEachPath(in, checkASCIIVizOutput)
+		}})
+	Register("CHECK_MEMORY_ERRORS", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestMemoryErrors{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestMemoryErrors)
+			return forEachPath(in, func(p string) error { return checkMemoryErrors(p, c) })
+		}})
+	Register("DIFF_FILE_CONTENT", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestDiffFileContent{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestDiffFileContent)
+			return forEachPath(in, func(p string) error {
+				return diffFileContent(in.Test.Path, p, c.TemplateFile, c.TemplateParameters)
+			})
+		}})
+	Register("FILE_MATCH_PATTERN", &checkFunc{data: NoData,
+		options: func() interface{} { return &tomlParser.TestPatternMatch{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestPatternMatch)
+			return forEachPath(in, func(p string) error {
+				return fileMatchPattern(p, c.MatchPattern, c.NumMatches)
+			})
+		}})
+	Register("CHECK_EXPRESSIONS", &checkFunc{data: NoData,
+		check: func(in *CheckInput) error {
+			return forEachPath(in, checkExpressions)
+		}})
+	Register("COUNT_CONSTRAINTS", &checkFunc{data: NumericData,
+		options: func() interface{} { return &tomlParser.TestConstraints{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestConstraints)
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkCountConstraints(d, p, in.Case.MinTime, in.Case.MaxTime,
+					c.CountConstraints)
+			})
+		}})
+	Register("COUNT_MINMAX", &checkFunc{data: NumericData,
+		options: func() interface{} { return &tomlParser.TestMinMax{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestMinMax)
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkCountMinmax(d, p, in.Case.MinTime, in.Case.MaxTime,
+					c.CountMaximum, c.CountMinimum)
+			})
+		}})
+	Register("COMPARE_COUNTS", &checkFunc{data: NumericData,
+		options: func() interface{} { return &tomlParser.TestCompareCounts{} },
+		check:   checkCompareCounts})
+	Register("COUNT_EQUILIBRIUM", &checkFunc{data: NumericData,
+		options: func() interface{} { return &tomlParser.TestMeans{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestMeans)
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkCountEquilibrium(d, p, in.Case.MinTime, in.Case.MaxTime,
+					c.Means, c.Tolerances)
+			})
+		}})
+	Register("COUNT_MEAN_TEST", &checkFunc{data: NumericData,
+		options: func() interface{} { return &meanTestOptions{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*meanTestOptions)
+			return checkCountMeanTest(in.Data, in.Case.DataFile, in.Case.MinTime,
+				in.Case.MaxTime, c.Means, c.StatTest, c.Significance)
+		}})
+	Register("POSITIVE_COUNTS", &checkFunc{data: NumericData,
+		check: func(in *CheckInput) error {
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkPositiveOrZeroCounts(d, p, in.Case.MinTime, in.Case.MaxTime, false)
+			})
+		}})
+	Register("POSITIVE_OR_ZERO_COUNTS", &checkFunc{data: NumericData,
+		check: func(in *CheckInput) error {
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkPositiveOrZeroCounts(d, p, in.Case.MinTime, in.Case.MaxTime, true)
+			})
+		}})
+	Register("ZERO_COUNTS", &checkFunc{data: NumericData,
+		check: func(in *CheckInput) error {
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return checkZeroCounts(d, p, in.Case.MinTime, in.Case.MaxTime)
+			})
+		}})
+	Register("COUNT_RATES", &checkFunc{data: NumericData,
+		options: func() interface{} { return &countRatesOptions{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*countRatesOptions)
+			return forEachSeed(in, func(d *file.Columns, p string) error {
+				return countRates(d, p, in.Case.MinTime, in.Case.MaxTime, c.BaseTime,
+					c.Means, c.Tolerances)
+			})
+		}})
+	Register("CHECK_TRIGGERS", &checkFunc{data: StringData,
+		options: func() interface{} { return &tomlParser.TestTrigger{} },
+		check: func(in *CheckInput) error {
+			c := in.Options.(*tomlParser.TestTrigger)
+			for i, d := range in.StringData {
+				if err := checkTriggers(d, in.DataPaths[i], in.Case.MinTime,
+					in.Case.MaxTime, c.TriggerType, c.HaveExactTime, c.OutputTime,
+					c.Xrange, c.Yrange, c.Zrange); err != nil {
+					return err
+				}
+			}
+			return nil
+		}})
+}
+
+// forEachPath runs check on the data file of each seed and returns the first
+// failure
+func forEachPath(in *CheckInput, check func(dataPath string) error) error {
+	for _, p := range in.DataPaths {
+		if err := check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachSeed runs check on the loaded data of each seed and returns the
+// first failure
+func forEachSeed(in *CheckInput, check func(data *file.Columns,
+	dataPath string) error) error {
+	for i, d := range in.Data {
+		if err := check(d, in.DataPaths[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSuccess tests that all simulation runs of the test succeeded. Since
+// checks of failed simulations are meaningless, the remaining checks of the
+// test are skipped if it fails.
+func checkSuccess(in *CheckInput) error {
+	test := in.Test
+	if test.SimStatus == nil {
+		return &CheckError{Category: HarnessError, Abort: true,
+			Err: errors.New("simulations did not run or return an exit status")}
+	}
+
+	// in order to cut down on the amount of output (particularly in the case of
+	// multiple seeds) we return failure if one or more of all runs within a test
+	// fails and success otherwise
+	for _, testRun := range test.SimStatus {
+		if !testRun.Success {
+			exitMessage := testRun.ExitMessage
+			if testRun.TimedOut {
+				exitMessage = "simulation timed out: " + exitMessage
+			}
+			messages := []string{exitMessage}
+			if testRun.Backtrace != "" {
+				messages = append(messages, "backtrace:\n"+testRun.Backtrace)
+			}
+			messages = append(messages, testRun.StdErrContent)
+			message := test.seedPrefix(testRun) + strings.Join(messages, "\n")
+			category := SimulatorCrash
+			if testRun.HarnessError {
+				category = HarnessError
+			}
+			run := testRun
+			return &CheckError{Err: errors.New(message), Category: category,
+				Run: &run, Abort: true}
+		}
+	}
+	return nil
+}
+
+// checkExitCode tests that all simulation runs of the test exited with the
+// expected exit code and reports the first run which didn't
+func checkExitCode(in *CheckInput) error {
+	c := in.Options.(*tomlParser.TestExitCode)
+	for _, testRun := range in.Test.SimStatus {
+		if c.ExitCode != testRun.ExitCode {
+			run := testRun
+			return &CheckError{Category: CheckFailure, Run: &run,
+				Err: fmt.Errorf("%sExpected exit code %d but got %d instead",
+					in.Test.seedPrefix(testRun), c.ExitCode, testRun.ExitCode)}
+		}
+	}
+	return nil
+}
+
+// checkCheckPoints tests the checkpoint timing in the working directory of
+// each seed
+func checkCheckPoints(in *CheckInput) error {
+	c := in.Options.(*tomlParser.TestCheckPoint)
+	test := in.Test
+	for _, seed := range test.Run.Seeds {
+		runDir := file.GetRunDir(test.Path, seed, test.Run.NumSeeds)
+		if err := checkCheckPoint(runDir, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCompareCounts compares the data of each seed against the reference
+// counts
+func checkCompareCounts(in *CheckInput) error {
+	c := in.Options.(*tomlParser.TestCompareCounts)
+	common := in.Case
+
+	// only one of absDeviation, relDeviation, or stdErrDeviation can be defined
+	haveAbsDev := len(c.AbsDeviation) > 0
+	haveRelDev := len(c.RelDeviation) > 0
+	haveStdErrDev := c.StdErrDeviation > 0
+	if (haveAbsDev && haveRelDev) || (haveStdErrDev && (haveAbsDev || haveRelDev)) {
+		return fmt.Errorf("absDeviation, relDeviation, and stdErrDeviation " +
+			"are mutually exclusive")
+	}
+	if haveStdErrDev && !common.AverageData {
+		return fmt.Errorf("stdErrDeviation requires averageData")
+	}
+
+	referencePath := filepath.Join(in.Test.Path, c.ReferenceFile)
+	refData, err := file.ReadCounts(referencePath, common.HaveHeader)
+	if err == nil && len(common.Columns) > 0 {
+		refData, err = refData.SelectColumns(common.Columns)
+		if err != nil {
+			err = fmt.Errorf("in %s: %v", referencePath, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return forEachSeed(in, func(d *file.Columns, p string) error {
+		return compareCounts(d, refData, c.AbsDeviation, c.RelDeviation,
+			c.StdErrDeviation, p, common.MinTime, common.MaxTime)
+	})
+}
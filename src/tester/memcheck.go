@@ -0,0 +1,196 @@
+// Copyright 2014-2016 Markus Dittrich. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tester
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mcellteam/nutmeg/src/tomlParser"
+)
+
+// default number of stack frames reported per memory error
+const defaultNumFrames = 3
+
+// maximum number of memory errors described in failure messages
+const maxReportedErrors = 5
+
+// memoryError describes a single error or leak from a memory checker report
+type memoryError struct {
+	kind        string   // kind of error, e.g., InvalidRead or AddressSanitizer
+	what        string   // description of the error
+	leakedBytes int      // number of leaked bytes (leaks only)
+	frames      []string // innermost stack frames
+}
+
+// memoryReport summarizes a valgrind or sanitizer report
+type memoryReport struct {
+	numErrors   int           // number of memory errors excluding leaks
+	leakedBytes int           // number of definitely or indirectly leaked bytes
+	errors      []memoryError // all errors and leaks in order of appearance
+}
+
+// checkMemoryErrors parses the valgrind XML or sanitizer report at dataPath
+// and checks that the number of memory errors and leaked bytes are within
+// the limits set by the check. Reports in XML format are assumed to come
+// from valgrind, all others from the address, leak, or undefined behavior
+// sanitizer.
+func checkMemoryErrors(dataPath string, c *tomlParser.TestMemoryErrors) error {
+	content, err := ioutil.ReadFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to open memory checker report %s", dataPath)
+	}
+
+	numFrames := c.NumFrames
+	if numFrames <= 0 {
+		numFrames = defaultNumFrames
+	}
+
+	var report *memoryReport
+	if bytes.Contains(content, []byte("<valgrindoutput")) {
+		if report, err = parseValgrindXML(content, numFrames); err != nil {
+			return fmt.Errorf("failed to parse valgrind report %s: %v", dataPath, err)
+		}
+	} else {
+		report = parseSanitizerReport(content, numFrames)
+	}
+
+	if report.numErrors <= c.MaxErrors && report.leakedBytes <= c.MaxLeakedBytes {
+		return nil
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "%s: %d memory errors and %d leaked bytes (allowed: %d "+
+		"errors and %d leaked bytes)", filepath.Base(dataPath), report.numErrors,
+		report.leakedBytes, c.MaxErrors, c.MaxLeakedBytes)
+	for i, e := range report.errors {
+		if i == maxReportedErrors {
+			fmt.Fprintf(&msg, "\n\t... and %d more", len(report.errors)-i)
+			break
+		}
+		fmt.Fprintf(&msg, "\n\t%s: %s", e.kind, e.what)
+		for _, f := range e.frames {
+			fmt.Fprintf(&msg, "\n\t\tat %s", f)
+		}
+	}
+	return fmt.Errorf("%s", msg.String())
+}
+
+// valgrindOutput describes the parts of valgrind's XML output needed to
+// summarize memory errors
+type valgrindOutput struct {
+	Errors []struct {
+		Kind  string `xml:"kind"`
+		What  string `xml:"what"`
+		XWhat struct {
+			Text        string `xml:"text"`
+			LeakedBytes int    `xml:"leakedbytes"`
+		} `xml:"xwhat"`
+		Frames []struct {
+			Fn   string `xml:"fn"`
+			File string `xml:"file"`
+			Line int    `xml:"line"`
+			Obj  string `xml:"obj"`
+		} `xml:"stack>frame"`
+	} `xml:"error"`
+}
+
+// parseValgrindXML summarizes the errors in a valgrind XML report (as
+// produced by valgrind --xml=yes). Only definite and indirect leaks count
+// towards the leaked bytes; possibly lost or still reachable memory is
+// ignored.
+func parseValgrindXML(content []byte, numFrames int) (*memoryReport, error) {
+	var output valgrindOutput
+	if err := xml.Unmarshal(content, &output); err != nil {
+		return nil, err
+	}
+
+	var report memoryReport
+	for _, e := range output.Errors {
+		merr := memoryError{kind: e.Kind, what: e.What}
+		switch {
+		case e.Kind == "Leak_DefinitelyLost" || e.Kind == "Leak_IndirectlyLost":
+			merr.what = e.XWhat.Text
+			merr.leakedBytes = e.XWhat.LeakedBytes
+			report.leakedBytes += e.XWhat.LeakedBytes
+		case strings.HasPrefix(e.Kind, "Leak_"):
+			continue
+		default:
+			report.numErrors++
+		}
+
+		for i, f := range e.Frames {
+			if i == numFrames {
+				break
+			}
+			frame := f.Fn
+			if frame == "" {
+				frame = "???"
+			}
+			if f.File != "" {
+				frame += fmt.Sprintf(" (%s:%d)", f.File, f.Line)
+			} else if f.Obj != "" {
+				frame += fmt.Sprintf(" (%s)", f.Obj)
+			}
+			merr.frames = append(merr.frames, frame)
+		}
+		report.errors = append(report.errors, merr)
+	}
+	return &report, nil
+}
+
+// regular expressions matching the relevant lines of sanitizer reports
+var (
+	sanitizerErrorRegex = regexp.MustCompile(`ERROR: (\w+Sanitizer): ([\w-]+)(.*)`)
+	ubsanErrorRegex     = regexp.MustCompile(`^(.*): runtime error: (.*)`)
+	leakRegex           = regexp.MustCompile(`^(Direct|Indirect) leak of (\d+) byte`)
+	frameRegex          = regexp.MustCompile(`^\s*#\d+ 0x[0-9a-fA-F]+ in (.*)`)
+)
+
+// parseSanitizerReport summarizes the errors and leaks reported by the
+// address, leak, or undefined behavior sanitizer
+func parseSanitizerReport(content []byte, numFrames int) *memoryReport {
+	var report memoryReport
+	var current *memoryError
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := sanitizerErrorRegex.FindStringSubmatch(line); m != nil {
+			// leaks are reported individually below
+			if m[1] == "LeakSanitizer" {
+				current = nil
+				continue
+			}
+			report.numErrors++
+			report.errors = append(report.errors, memoryError{kind: m[1],
+				what: m[2] + m[3]})
+			current = &report.errors[len(report.errors)-1]
+		} else if m := ubsanErrorRegex.FindStringSubmatch(line); m != nil {
+			report.numErrors++
+			report.errors = append(report.errors, memoryError{
+				kind: "UndefinedBehaviorSanitizer", what: m[2], frames: []string{m[1]}})
+			current = &report.errors[len(report.errors)-1]
+		} else if m := leakRegex.FindStringSubmatch(line); m != nil {
+			numBytes, _ := strconv.Atoi(m[2])
+			report.leakedBytes += numBytes
+			what := strings.TrimSuffix(strings.TrimSpace(line), " allocated from:")
+			report.errors = append(report.errors, memoryError{kind: "LeakSanitizer",
+				what: what, leakedBytes: numBytes})
+			current = &report.errors[len(report.errors)-1]
+		} else if m := frameRegex.FindStringSubmatch(line); m != nil && current != nil {
+			if len(current.frames) < numFrames {
+				current.frames = append(current.frames, m[1])
+			}
+		}
+	}
+	return &report
+}
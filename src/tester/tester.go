@@ -31,7 +31,21 @@ type RunStatus struct {
 	Success       bool // indicates if prepping/running the simulation succeeded
 	ExitMessage   string
 	StdErrContent string
-	ExitCode      int // this is only used if mcell was actually run
+	ExitCode      int           // this is only used if mcell was actually run
+	TimedOut      bool          // indicates that mcell was killed after exceeding its timeout
+	Interrupted   bool          // indicates that nutmeg was interrupted before mcell finished
+	HarnessError  bool          // indicates that nutmeg failed to prepare or start the run
+	Cached        bool          // output was restored from the simulation cache
+	Signal        string        // signal which terminated mcell (empty if none)
+	CoreDumped    bool          // indicates that mcell dumped core
+	Backtrace     string        // backtrace extracted from the core dump
+	MdlFile       string        // name of the mdl file which was run
+	Seed          int           // seed value of the run
+	Args          []string      // commandline of the run including any wrapper
+	Dir           string        // working directory of the run
+	Start         time.Time     // start time of the mcell run
+	Duration      time.Duration // wall-clock time of the mcell run
+	CPUTime       time.Duration // user and system CPU time of the mcell run
 }
 
 // TestData contains the description of the test as well as the simulation status
@@ -40,232 +54,204 @@ type TestData struct {
 	SimStatus []RunStatus
 }
 
+// FailureCategory classifies the cause of a failed test
+type FailureCategory int
+
+// available failure categories
+const (
+	NoFailure      FailureCategory = iota // test succeeded
+	CheckFailure                          // a check of the simulation output failed
+	SimulatorCrash                        // MCell failed or did not finish
+	HarnessError                          // nutmeg failed to set up, run or check the test
+)
+
+// String returns a textual description of the failure category
+func (f FailureCategory) String() string {
+	switch f {
+	case NoFailure:
+		return "no failure"
+	case CheckFailure:
+		return "check failure"
+	case SimulatorCrash:
+		return "simulator crash"
+	case HarnessError:
+		return "harness error"
+	}
+	return "unknown failure"
+}
+
 // TestResult encapsulates the results of an individual test
+// NOTE: results which do not stem from a check (e.g. failure to parse the
+// test description) have a CheckIndex of -1
 type TestResult struct {
-	Path         string // path to test which was run
-	Success      bool   // was test successful
-	TestName     string // name of test
-	ErrorMessage string // error message if test failed
+	Path          string          // path to test which was run
+	Success       bool            // was test successful
+	TestName      string          // name of test
+	ErrorMessage  string          // error message if test failed
+	StdErrContent string          // stderr of the simulation runs if test failed
+	Duration      time.Duration   // wall-clock time spent on the test
+	CheckIndex    int             // index of check within the test description
+	Description   string          // description of the check
+	Seed          int             // seed of the simulation run
+	NumSeeds      int             // number of seeds run for the test
+	Seeds         []int           // seeds of all simulation runs of the test
+	Interrupted   bool            // test was interrupted before its simulations finished
+	Category      FailureCategory // cause of the failure if test failed
 }
 
 // Run analyses the TestDescriptions coming from an MCell run on a
-// test and analyses them as requested per the TestDescription.
+// test and analyses them as requested per the TestDescription. Each check
+// is dispatched to the Checker registered for its testType.
 func Run(test *TestData, result chan *TestResult) {
 
-	// tests which don't require loading of reaction data output
-	nonDataParseTests := []string{"DIFF_FILE_CONTENT", "FILE_MATCH_PATTERN",
-		"CHECK_TRIGGERS", "CHECK_EXPRESSIONS", "CHECK_LEGACY_VOL_OUTPUT",
-		"CHECK_EMPTY_FILE", "CHECK_ASCII_VIZ_OUTPUT", "CHECK_CHECKPOINT"}
+	// checks on the output of interrupted simulations are meaningless
+	for _, testRun := range test.SimStatus {
+		if testRun.Interrupted {
+			result <- &TestResult{Path: test.Path, Success: false, TestName: "interrupted",
+				ErrorMessage: testRun.ExitMessage, CheckIndex: -1, Seed: testRun.Seed,
+				NumSeeds: test.Run.NumSeeds, Seeds: test.Run.Seeds, Interrupted: true,
+				Category: HarnessError}
+			return
+		}
+	}
 
-	for _, c := range test.Checks {
+	for i, c := range test.Checks {
 
-		dataPaths, err := file.GetDataPaths(test.Path, c.DataFile, test.Run.Seed,
-			test.Run.NumSeeds)
+		start := time.Now()
+		checker, err := lookupChecker(c.TestType)
 		if err != nil {
-			result <- &TestResult{test.Path, false, c.TestType, fmt.Sprint(err)}
+			recordFailure(result, test, i, c, start, HarnessError, err)
 			continue
 		}
 
-		// load the data for test types which need it
-		var data []*file.Columns
-		var stringData []*file.StringColumns
-		// NOTE: only attempt to parse data for the test cases which need it
-		if c.DataFile != "" && !misc.ContainsString(nonDataParseTests, c.TestType) {
-			data, err = file.LoadData(dataPaths, c.HaveHeader, c.AverageData)
-			if err != nil {
-				result <- &TestResult{test.Path, false, c.TestType, fmt.Sprint(err)}
-				continue
-			}
-		} else if c.TestType == "CHECK_TRIGGERS" {
-			stringData, err = file.LoadStringData(dataPaths, c.HaveHeader)
-			if err != nil {
-				result <- &TestResult{test.Path, false, c.TestType, fmt.Sprint(err)}
+		in := &CheckInput{Test: test, Case: c, Options: checker.Options()}
+		if in.Options != nil {
+			if err := c.DecodeOptions(in.Options); err != nil {
+				recordFailure(result, test, i, c, start, HarnessError,
+					fmt.Errorf("invalid options for %s: %v", c.TestType, err))
 				continue
 			}
 		}
 
-		// execute requested tests on data
-		var testErr error
-		switch c.TestType {
-		case "CHECK_SUCCESS":
-			if test.SimStatus == nil {
-				result <- &TestResult{test.Path, false, "CHECK_SUCCESS",
-					"simulations did not run or return an exit status"}
-				return // if simulation fails we won't continue testing
-			}
-
-			// in order to cut down on the amount of output (particularly in the case of
-			// multiple seeds) we return failure if one or more of all runs within a test
-			// fails and success otherwise
-			for _, testRun := range test.SimStatus {
-				if !testRun.Success {
-					message := strings.Join([]string{testRun.ExitMessage, testRun.StdErrContent}, "\n")
-					result <- &TestResult{test.Path, false, "CHECK_SUCCESS", message}
-					return // if simulation fails we won't continue testing
-				}
-			}
-
-		case "CHECK_EXIT_CODE":
-			for _, testRun := range test.SimStatus {
-				if c.ExitCode != testRun.ExitCode {
-					testErr = fmt.Errorf("Expected exit code %d but got %d instead",
-						c.ExitCode, testRun.ExitCode)
-				}
-			}
-
-		case "CHECK_NONEMPTY_FILES":
-			if testErr = checkFilesEmpty(test, c, false); testErr != nil {
-				break
-			}
-
-		case "CHECK_EMPTY_FILES":
-			if testErr = checkFilesEmpty(test, c, true); testErr != nil {
-				break
-			}
-
-		case "CHECK_CHECKPOINT":
-			if testErr = checkCheckPoint(test.Path, c); testErr != nil {
-				break
-			}
-
-		case "CHECK_LEGACY_VOL_OUTPUT":
-			for _, p := range dataPaths {
-				if testErr = checkLegacyVolOutput(p, c); testErr != nil {
-					break
-				}
-			}
-
-		case "CHECK_ASCII_VIZ_OUTPUT":
-			for _, p := range dataPaths {
-				if testErr = checkASCIIVizOutput(p); testErr != nil {
-					break
-				}
-			}
-
-		case "DIFF_FILE_CONTENT":
-			for _, p := range dataPaths {
-				if testErr = diffFileContent(test.Path, p, c.TemplateFile,
-					c.TemplateParameters); testErr != nil {
-					break
-				}
-			}
-
-		case "COUNT_CONSTRAINTS":
-			for i, d := range data {
-				if testErr = checkCountConstraints(d, dataPaths[i], c.MinTime, c.MaxTime,
-					c.CountConstraints); testErr != nil {
-					break
-				}
-			}
-
-		case "COUNT_MINMAX":
-			for i, d := range data {
-				if testErr = checkCountMinmax(d, dataPaths[i], c.MinTime, c.MaxTime,
-					c.CountMaximum, c.CountMinimum); testErr != nil {
-					break
-				}
-			}
-
-		case "FILE_MATCH_PATTERN":
-			for _, dataPath := range dataPaths {
-				if testErr = fileMatchPattern(dataPath, c.MatchPattern, c.NumMatches); testErr != nil {
-					break
-				}
-			}
-
-		case "CHECK_EXPRESSIONS":
-			for _, dataPath := range dataPaths {
-				if testErr = checkExpressions(dataPath); testErr != nil {
-					break
-				}
-			}
-
-		case "COMPARE_COUNTS":
-			// only one of absDeviation or relDeviation can be defined
-			if (len(c.AbsDeviation) > 0) && (len(c.RelDeviation) > 0) {
-				testErr = fmt.Errorf("absDeviation and relDeviation are mutually exclusive")
-				break
-			}
+		in.DataPaths, err = file.GetDataPaths(test.Path, c.DataFile, test.Run.Seeds)
+		if err != nil {
+			recordResult(result, test, i, c, start, err)
+			continue
+		}
 
-			referencePath := filepath.Join(test.Path, c.ReferenceFile)
-			refData, err := file.ReadCounts(referencePath, c.HaveHeader)
-			if err != nil {
-				testErr = err
+		// load the data for test types which need it
+		switch checker.Data() {
+		case NumericData:
+			if c.DataFile == "" {
 				break
 			}
-			for i, d := range data {
-				if testErr = compareCounts(d, refData, c.AbsDeviation, c.RelDeviation,
-					dataPaths[i], c.MinTime, c.MaxTime); testErr != nil {
-					break
-				}
+			in.Data, err = file.LoadData(in.DataPaths, c.HaveHeader, c.AverageData)
+			if err == nil && len(c.Columns) > 0 {
+				in.Data, err = selectColumns(in.Data, in.DataPaths, c.Columns)
 			}
+		case StringData:
+			in.StringData, err = file.LoadStringData(in.DataPaths, c.HaveHeader)
+		}
+		if err != nil {
+			recordResult(result, test, i, c, start, err)
+			continue
+		}
 
-		case "COUNT_EQUILIBRIUM":
-			for i, d := range data {
-				if testErr = checkCountEquilibrium(d, dataPaths[i], c.MinTime, c.MaxTime,
-					c.Means, c.Tolerances); testErr != nil {
-					break
-				}
+		// execute requested test on data
+		testErr := checker.Check(in)
+		if checkErr, ok := testErr.(*CheckError); ok {
+			if checkErr.Run != nil {
+				recordRunFailure(result, test, i, c, start, checkErr.Category,
+					*checkErr.Run, checkErr.Err)
+			} else {
+				recordFailure(result, test, i, c, start, checkErr.Category, checkErr.Err)
 			}
-
-		case "POSITIVE_COUNTS":
-			for i, d := range data {
-				if testErr = checkPositiveOrZeroCounts(d, dataPaths[i], c.MinTime,
-					c.MaxTime, false); testErr != nil {
-					break
-				}
+			if checkErr.Abort {
+				return
 			}
+			continue
+		}
+		recordResult(result, test, i, c, start, testErr)
+	}
+}
 
-		case "POSITIVE_OR_ZERO_COUNTS":
-			for i, d := range data {
-				if testErr = checkPositiveOrZeroCounts(d, dataPaths[i], c.MinTime,
-					c.MaxTime, true); testErr != nil {
-					break
-				}
-			}
+// recordResults checks if a test was successful or not, records
+// success/failure in TestResult object and sends it to the results channel.
+// The duration of the test is measured starting at start. Failures are
+// recorded as check failures.
+func recordResult(result chan<- *TestResult, test *TestData, checkID int,
+	c *tomlParser.TestCase, start time.Time, err error) {
+	recordFailure(result, test, checkID, c, start, CheckFailure, err)
+}
 
-		case "ZERO_COUNTS":
-			for i, d := range data {
-				if testErr = checkZeroCounts(d, dataPaths[i], c.MinTime,
-					c.MaxTime); testErr != nil {
-					break
-				}
-			}
+// recordFailure works like recordResult but records failures with the
+// provided failure category
+func recordFailure(result chan<- *TestResult, test *TestData, checkID int,
+	c *tomlParser.TestCase, start time.Time, category FailureCategory, err error) {
+	result <- newResult(test, checkID, c, start, category, err)
+}
 
-		case "COUNT_RATES":
-			for i, d := range data {
-				if testErr = countRates(d, dataPaths[i], c.MinTime, c.MaxTime,
-					c.BaseTime, c.Means, c.Tolerances); testErr != nil {
-					break
-				}
-			}
+// recordRunFailure records the failure of a check caused by the simulation
+// run testRun. The result refers to the seed and stderr output of testRun.
+func recordRunFailure(result chan<- *TestResult, test *TestData, checkID int,
+	c *tomlParser.TestCase, start time.Time, category FailureCategory,
+	testRun RunStatus, err error) {
+	r := newResult(test, checkID, c, start, category, err)
+	r.Seed = testRun.Seed
+	r.StdErrContent = testRun.StdErrContent
+	result <- r
+}
 
-		case "CHECK_TRIGGERS":
-			for i, d := range stringData {
-				if testErr = checkTriggers(d, dataPaths[i], c.MinTime, c.MaxTime,
-					c.TriggerType, c.HaveExactTime, c.OutputTime, c.Xrange, c.Yrange,
-					c.Zrange); testErr != nil {
-					break
-				}
-			}
+// newResult assembles the TestResult of a check
+func newResult(test *TestData, checkID int, c *tomlParser.TestCase,
+	start time.Time, category FailureCategory, err error) *TestResult {
+	r := &TestResult{Path: test.Path, Success: true, TestName: c.TestType,
+		Duration: time.Since(start), CheckIndex: checkID, Description: c.Description,
+		Seed: test.Run.Seed, NumSeeds: test.Run.NumSeeds, Seeds: test.Run.Seeds}
+	if err != nil {
+		r.Success = false
+		r.ErrorMessage = fmt.Sprint(err)
+		r.StdErrContent = test.stdErrContent()
+		r.Category = category
+	}
+	return r
+}
 
-		default:
-			testErr = fmt.Errorf("Unknown test type: %s", c.TestType)
-			break
+// stdErrContent returns the combined stderr content of all simulation runs
+// of a test
+func (t *TestData) stdErrContent() string {
+	var content []string
+	for _, s := range t.SimStatus {
+		if s.StdErrContent != "" {
+			content = append(content, s.StdErrContent)
 		}
-		recordResult(result, c.TestType, test.Path, testErr)
 	}
+	return strings.Join(content, "\n")
 }
 
-// recordResults checks if a test was successful or not, records
-// success/failure in TestResult object and sends it to the results channel
-func recordResult(result chan<- *TestResult, testType string,
-	dataPath string, err error) {
-	if err != nil {
-		result <- &TestResult{dataPath, false, testType, fmt.Sprint(err)}
-	} else {
-		result <- &TestResult{dataPath, true, testType, ""}
+// seedPrefix returns the prefix identifying the seed of the simulation run
+// testRun in error messages of multi seed tests
+func (t *TestData) seedPrefix(testRun RunStatus) string {
+	if t.Run.NumSeeds <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("seed %d: ", testRun.Seed)
+}
+
+// selectColumns restricts each of the provided data sets to the data columns
+// with the given names
+func selectColumns(data []*file.Columns, dataPaths []string,
+	names []string) ([]*file.Columns, error) {
+
+	var selection []*file.Columns
+	for i, d := range data {
+		s, err := d.SelectColumns(names)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %v", dataPaths[i], err)
+		}
+		selection = append(selection, s)
 	}
+	return selection, nil
 }
 
 // checkCountConstraints tests the provided array of constraints
@@ -286,13 +272,13 @@ func checkCountConstraints(data *file.Columns, dataPath string, minTime,
 					dataPath, len(data.Counts), len(con.Query))
 			}
 
-			result := 0
+			result := 0.0
 			for c, q := range con.Query {
 				result += (q * data.Counts[c][r])
 			}
 
-			if result != con.Target {
-				return fmt.Errorf("in %s: constraint mismatch: result (%d) - actual (%d)",
+			if !misc.ApproxEqual(result, con.Target, 0) {
+				return fmt.Errorf("in %s: constraint mismatch: result (%g) - actual (%g)",
 					dataPath, result, con.Target)
 			}
 		}
@@ -304,7 +290,7 @@ func checkCountConstraints(data *file.Columns, dataPath string, minTime,
 // checkCountMinmax tests that each column of the parsed data is larger
 // equal than CountMinimum and smaller equal than CountMaximum.
 func checkCountMinmax(data *file.Columns, dataPath string, minTime, maxTime float64,
-	countMaximum, countMinimum []int) error {
+	countMaximum, countMinimum []float64) error {
 
 	if countMaximum != nil && len(countMaximum) != len(data.Counts) {
 		return fmt.Errorf(
@@ -326,11 +312,11 @@ func checkCountMinmax(data *file.Columns, dataPath string, minTime, maxTime floa
 		for i := 0; i < len(data.Counts); i++ {
 			c := data.Counts[i][r]
 			if countMaximum != nil && c > countMaximum[i] {
-				return fmt.Errorf("in %s: maximum exceeded: data (%d) > max(%d)", dataPath,
+				return fmt.Errorf("in %s: maximum exceeded: data (%g) > max(%g)", dataPath,
 					c, countMaximum[i])
 			}
 			if countMinimum != nil && c < countMinimum[i] {
-				return fmt.Errorf("in %s: minimum undershot: data (%d) < min(%d)", dataPath,
+				return fmt.Errorf("in %s: minimum undershot: data (%g) < min(%g)", dataPath,
 					c, countMinimum[i])
 			}
 		}
@@ -361,9 +347,12 @@ func fileMatchPattern(filePath string, matchPattern string,
 }
 
 // compareCounts checks that the test data matches the provided column counts
-// exactly
-func compareCounts(data, refData *file.Columns, absDev []int, relDev []float64,
-	dataPath string, minTime, maxTime float64) error {
+// exactly or within the allowed absolute or relative deviation. Floating
+// point data is considered to match if it is equal up to rounding. For
+// averaged data the allowed deviation can also be given as a multiple
+// stdErrDev of the standard error of each data item.
+func compareCounts(data, refData *file.Columns, absDev, relDev []float64,
+	stdErrDev float64, dataPath string, minTime, maxTime float64) error {
 
 	if len(refData.Times) != len(data.Times) {
 		return fmt.Errorf(
@@ -380,7 +369,7 @@ func compareCounts(data, refData *file.Columns, absDev []int, relDev []float64,
 	numCols := len(data.Counts)
 	// pad absDev and relDev arrays with zeros if necessary
 	for i := len(absDev); i < numCols; i++ {
-		absDev = append(absDev, 0)
+		absDev = append(absDev, 0.0)
 	}
 	for i := len(relDev); i < numCols; i++ {
 		relDev = append(relDev, 0.0)
@@ -394,12 +383,14 @@ func compareCounts(data, refData *file.Columns, absDev []int, relDev []float64,
 		for c := 0; c < numCols; c++ {
 			// determine allowed deviation if defined via absDeviation or relDeviation
 			dev := absDev[c]
-			if dev == 0 {
-				dev = int(relDev[c] * float64(refData.Counts[c][r]))
+			if stdErrDev > 0 && data.StdErrs != nil {
+				dev = stdErrDev * data.StdErrs[c][r]
+			} else if dev == 0 {
+				dev = math.Abs(relDev[c] * refData.Counts[c][r])
 			}
-			if misc.Abs(data.Counts[c][r]-refData.Counts[c][r]) > dev {
+			if !misc.ApproxEqual(data.Counts[c][r], refData.Counts[c][r], dev) {
 				return fmt.Errorf("in %s: reference and actual data differ in row %d "+
-					"and col %d (expected: %d +/- %d actual value: %d)", dataPath, r, c,
+					"and col %d (expected: %g +/- %g actual value: %g)", dataPath, r, c,
 					refData.Counts[c][r], dev, data.Counts[c][r])
 			}
 		}
@@ -432,7 +423,7 @@ func countRates(data *file.Columns, dataPath string, minTime, maxTime, baseTime
 
 		numValues++
 		for c := 0; c < numCols; c++ {
-			averageRate[c] += float64(data.Counts[c][r]) / (time - baseTime)
+			averageRate[c] += data.Counts[c][r] / (time - baseTime)
 		}
 	}
 
@@ -646,7 +637,7 @@ func checkCountEquilibrium(data *file.Columns, dataPath string, minTime, maxTime
 
 		numValues++
 		for c := 0; c < numCols; c++ {
-			averages[c] += float64(data.Counts[c][r])
+			averages[c] += data.Counts[c][r]
 		}
 	}
 
@@ -661,16 +652,99 @@ func checkCountEquilibrium(data *file.Columns, dataPath string, minTime, maxTime
 	return nil
 }
 
+// checkCountMeanTest tests statistically whether the column means of the data
+// of a multi seed run are consistent with the provided expected means. For
+// each column, the time averages of all seeds are computed first. The mean
+// and standard error of these per seed averages are then used to perform a
+// t-test or z-test against the expected mean at the requested significance.
+func checkCountMeanTest(data []*file.Columns, dataFile string, minTime,
+	maxTime float64, means []float64, statTest string, significance float64) error {
+
+	if len(data) < 2 {
+		return fmt.Errorf("in %s: statistical tests require at least two seeds "+
+			"with non-averaged data (found %d data sets)", dataFile, len(data))
+	}
+
+	if significance == 0 {
+		significance = 0.05
+	}
+	if significance < 0 || significance >= 1 {
+		return fmt.Errorf("invalid significance level %f", significance)
+	}
+
+	var pValue func(t, df float64) float64
+	switch statTest {
+	case "", "t":
+		pValue = misc.StudentTPValue
+	case "z":
+		pValue = func(z, df float64) float64 { return misc.NormalPValue(z) }
+	default:
+		return fmt.Errorf("unknown statistical test %s", statTest)
+	}
+
+	numCols := len(data[0].Counts)
+	if len(means) != numCols {
+		return fmt.Errorf(
+			"in %s: number of provided means does not match number of data columns",
+			dataFile)
+	}
+
+	seedAverages := make([][]float64, numCols)
+	for _, d := range data {
+		if len(d.Counts) != numCols {
+			return fmt.Errorf("in %s: data sets have different number of columns",
+				dataFile)
+		}
+
+		averages := make([]float64, numCols)
+		var numValues int
+		for r, time := range d.Times {
+			if (minTime > 0 && time < minTime) || (maxTime > 0 && time > maxTime) {
+				continue
+			}
+
+			numValues++
+			for c := 0; c < numCols; c++ {
+				averages[c] += d.Counts[c][r]
+			}
+		}
+		if numValues == 0 {
+			return fmt.Errorf("in %s: no data within the requested time range", dataFile)
+		}
+
+		for c := 0; c < numCols; c++ {
+			seedAverages[c] = append(seedAverages[c], averages[c]/float64(numValues))
+		}
+	}
+
+	df := float64(len(data) - 1)
+	for c := 0; c < numCols; c++ {
+		mean, stdErr := misc.MeanAndStdErr(seedAverages[c])
+
+		var p float64
+		if stdErr == 0 {
+			if mean == means[c] {
+				p = 1
+			}
+		} else {
+			p = pValue((mean-means[c])/stdErr, df)
+		}
+
+		if p < significance {
+			return fmt.Errorf("in %s: mean %f +/- %f (standard error across %d seeds) "+
+				"of column %d differs significantly from expected mean %f "+
+				"(p-value %g < %g)", dataFile, mean, stdErr, len(data), c, means[c], p,
+				significance)
+		}
+	}
+	return nil
+}
+
 // checkPositiveOrZeroCounts tests that all counts of the data file are either > 0
 // (includeZero = false) or >= 0 (includeZero = true)
 func checkPositiveOrZeroCounts(data *file.Columns, dataPath string, minTime,
 	maxTime float64, includeZero bool) error {
 
-	lowerBound := 1
-	if includeZero {
-		lowerBound = 0
-	}
-
 	numCols := len(data.Counts)
 	for r, time := range data.Times {
 		if (minTime > 0 && time < minTime) || (maxTime > 0 && time > maxTime) {
@@ -678,9 +752,10 @@ func checkPositiveOrZeroCounts(data *file.Columns, dataPath string, minTime,
 		}
 
 		for c := 0; c < numCols; c++ {
-			if data.Counts[c][r] < lowerBound {
-				return fmt.Errorf("in %s value %d in column %d in row %d is not positive (<= 0)",
-					dataPath, data.Counts[c][r], c, r)
+			v := data.Counts[c][r]
+			if v < 0 || (v == 0 && !includeZero) {
+				return fmt.Errorf("in %s value %g in column %d in row %d is not positive (<= 0)",
+					dataPath, v, c, r)
 			}
 		}
 	}
@@ -700,7 +775,7 @@ func checkZeroCounts(data *file.Columns, dataPath string, minTime,
 
 		for c := 0; c < numCols; c++ {
 			if data.Counts[c][r] != 0 {
-				return fmt.Errorf("in %s value %d in column %d in row %d is non-zero",
+				return fmt.Errorf("in %s value %g in column %d in row %d is non-zero",
 					dataPath, data.Counts[c][r], c, r)
 			}
 		}
@@ -712,7 +787,7 @@ func checkZeroCounts(data *file.Columns, dataPath string, minTime,
 // checkFilesEmpty tests that all simulation output files listed were
 // created by the run and are either empty or non-empty depending on the
 // provided switch
-func checkFilesEmpty(test *TestData, c *tomlParser.TestCase,
+func checkFilesEmpty(test *TestData, c *tomlParser.TestFileSizes,
 	empty bool) error {
 
 	var fileList []string
@@ -745,7 +820,7 @@ func checkFilesEmpty(test *TestData, c *tomlParser.TestCase,
 
 	var badFileList []string
 	for _, fileName := range fileList {
-		filePaths, err := file.GetDataPaths(test.Path, fileName, test.Run.Seed, 1)
+		filePaths, err := file.GetDataPaths(test.Path, fileName, test.Run.Seeds)
 		if err != nil {
 			return fmt.Errorf("failed to construct data path for file %s:\n%s",
 				fileName, err)
@@ -768,10 +843,8 @@ func checkFilesEmpty(test *TestData, c *tomlParser.TestCase,
 }
 
 // checkCheckPoint tests that a checkpoint happened at the requested delay
-// in seconds (+/- margin)
-func checkCheckPoint(testDir string, c *tomlParser.TestCase) error {
-	path := file.GetOutputDir(testDir)
-
+// in seconds (+/- margin) in the working directory path of a simulation run
+func checkCheckPoint(path string, c *tomlParser.TestCheckPoint) error {
 	stamp := filepath.Join(path, c.BaseName+".stamp")
 	stampi, err := os.Stat(stamp)
 	if err != nil {
@@ -904,7 +977,7 @@ func diffFileContent(path, dataPath, templateFile string,
 // files such as presence of a header and the number of data items
 // NOTE: The header should look like
 //       # nx=25 ny=25 nz=25 time=100
-func checkLegacyVolOutput(dataPath string, c *tomlParser.TestCase) error {
+func checkLegacyVolOutput(dataPath string, c *tomlParser.TestLegacyVolOutput) error {
 
 	file, err := ioutil.ReadFile(dataPath)
 	if err != nil {
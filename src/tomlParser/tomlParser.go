@@ -7,6 +7,7 @@
 package tomlParser
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -16,9 +17,17 @@ import (
 
 // Config keeps track of package Configuration settings
 type Config struct {
-	McellPath  string // path to mcell executable
-	TestDir    string // path to directory with nutmeg tests
-	IncludeDir string // path to directory with nutmeg test include file
+	McellPath  string   // path to mcell executable
+	TestDir    string   // path to directory with nutmeg tests
+	IncludeDir string   // path to directory with nutmeg test include file
+	Timeout    float64  // default timeout in seconds for each MCell run (0 = no timeout)
+	CacheDir   string   // path to the simulation cache (empty = no caching)
+	CacheSize  float64  // maximum size of the simulation cache in MB (0 = unlimited)
+	Seed       int64    // master seed from which the seeds of single seed runs are derived (0 = random, or 1 if caching)
+	OutputDir  string   // root directory for test output (empty = output within each test directory)
+	Keep       string   // retention policy for test output: all (default), failed, or archive
+	Wrapper    []string // command prefix for running mcell, e.g., valgrind and its options
+	Backtrace  bool     // capture backtraces from the core dumps of crashed MCell runs
 }
 
 // TestDescription encapsulates all information needed to describe a unit
@@ -29,9 +38,9 @@ type TestDescription struct {
 	Description string
 	Path        string
 	KeyWords    []string
-	Includes    []string // names of JSON test description files to be included
-	Run         RunSpec  // simulation runs to conduct as part of this test
-	Checks      []*TestCase
+	Includes    []string    // names of JSON test description files to be included
+	Run         RunSpec     // simulation runs to conduct as part of this test
+	Checks      []*TestCase `toml:"-"` // parsed separately, see parseChecks
 	//	SimStatus   []RunStatus // status of all simulation runs
 }
 
@@ -40,38 +49,52 @@ type TestDescription struct {
 type RunSpec struct {
 	MdlFiles        []string // name of mdl file to run
 	NumSeeds        int      // number of seeds to run
+	Seeds           []int    // explicit seed values to run (overrides numSeeds)
 	CommandlineOpts []string // commandline options for this run
 	Seed            int      // seed value for this particular run
 	RunID           int      // unique ID for this run needed to collect results for multi seed runs
+	Timeout         float64  // timeout in seconds for each MCell run (0 = use default)
+	StageFiles      []string // input files copied into the working directory of each run
+	Wrapper         []string // command prefix for running mcell (overrides the default)
 }
 
-// TestCase describes an individual test case of an overall test
+// TestCase describes an individual test case of an overall test. Only the
+// options common to all checks are parsed up front. The options specific to
+// the check's TestType are decoded on demand via DecodeOptions into the option
+// struct of the check, e.g., TestMinMax for COUNT_MINMAX checks.
+// RawOptions holds all options of the check as given in the test description.
+// It isn't used for running checks but records the complete check in run
+// manifests.
 type TestCase struct {
 	TestCommon
-	TestCompareCounts
-	TestConstraints
-	TestExitCode
-	TestMeans
-	TestMinMax
-	TestPatternMatch
-	TestRates
-	TestTrigger
-	TestFileSizes
-	TestDiffFileContent
-	TestLegacyVolOutput
-	TestASCIIVizOutput
-	TestCheckPoint
+	RawOptions map[string]interface{} `toml:"-"`
+	options    toml.Primitive         // undecoded options of the test case
+	meta       *toml.MetaData         // metadata needed for decoding options
+}
+
+// DecodeOptions decodes the options of the test case into the struct pointed
+// to by v. Options not defined in v are ignored. Only test cases parsed from
+// a test description carry options to decode.
+func (t *TestCase) DecodeOptions(v interface{}) error {
+	if t.meta == nil {
+		return fmt.Errorf("no options available for %s check", t.TestType)
+	}
+	return t.meta.PrimitiveDecode(t.options, v)
 }
 
 // TestCommon includes common options that are used by two or more tests
+// If Columns is given, data based tests only consider the data columns with
+// the listed header names in the listed order. Per column settings such as
+// means or countMaximum then refer to the selected columns only.
 type TestCommon struct {
-	TestType    string  // test type - used to dispatch appropriate testing function
-	Description string  // textual description of test case
-	HaveHeader  bool    // indicates if DataFile contains a header
-	AverageData bool    // test averaged data (only useful for multiple seeds)
-	DataFile    string  // name of (output) file to test
-	MinTime     float64 // ignore all data items before MinTime for testing
-	MaxTime     float64 // ignore all data items after MaxTime for testing
+	TestType    string   // test type - used to dispatch appropriate testing function
+	Description string   // textual description of test case
+	HaveHeader  bool     // indicates if DataFile contains a header
+	AverageData bool     // test averaged data (only useful for multiple seeds)
+	DataFile    string   // name of (output) file to test
+	MinTime     float64  // ignore all data items before MinTime for testing
+	MaxTime     float64  // ignore all data items after MaxTime for testing
+	Columns     []string // names of data columns to test (requires HaveHeader)
 }
 
 // TestRates pertains to testing average reaction rates
@@ -86,8 +109,8 @@ type TestExitCode struct {
 
 // TestMinMax pertains to checks testing that data is within certain ranges
 type TestMinMax struct {
-	CountMaximum []int // test if counts are larger than provided minimum
-	CountMinimum []int // test if counts are smaller than provided maximum
+	CountMaximum []float64 // test if counts are larger than provided minimum
+	CountMinimum []float64 // test if counts are smaller than provided maximum
 }
 
 // TestConstraints pertains to checks testing that the data count columns
@@ -110,10 +133,23 @@ type TestPatternMatch struct {
 // they are assumed to be 0. Both absDeviation and relDeviation are arrays with
 // one value per data column. Any non-specified columns are assumed to be zero,
 // any additional values are ignored.
+// For averaged multi seed data, stdErrDeviation alternatively allows a
+// deviation of the given multiple of the standard error across seeds.
 type TestCompareCounts struct {
-	ReferenceFile string    // name of file with reference counts to compare against
-	AbsDeviation  []int     // allowed absolute deviation from reference, one per column
-	RelDeviation  []float64 // allowed relative deviation from reference, one per column
+	ReferenceFile   string    // name of file with reference counts to compare against
+	AbsDeviation    []float64 // allowed absolute deviation from reference, one per column
+	RelDeviation    []float64 // allowed relative deviation from reference, one per column
+	StdErrDeviation float64   // allowed deviation in multiples of the standard error
+}
+
+// TestMemoryErrors pertains to checks analysing the valgrind XML or
+// address/undefined behavior sanitizer reports of simulations run under a
+// wrapper command. Checks fail if the number of reported memory errors
+// or the number of leaked bytes exceeds the given maximum.
+type TestMemoryErrors struct {
+	MaxErrors      int // maximum number of memory errors
+	MaxLeakedBytes int // maximum number of definitely or indirectly leaked bytes
+	NumFrames      int // number of stack frames to report per error (0 = default)
 }
 
 // TestMeans pertains to checks testing that data values have a certain mean
@@ -123,6 +159,16 @@ type TestMeans struct {
 	Tolerances []float64 // tolerances by which actual column means may deviate from target
 }
 
+// TestHypothesis pertains to checks testing statistically whether the
+// column means of multi seed runs are consistent with the expected Means.
+// StatTest selects either Student's t-test ("t", the default) or a z-test
+// ("z"). The null hypothesis that a column mean equals the expected mean is
+// rejected if the p-value falls below Significance (default 0.05).
+type TestHypothesis struct {
+	StatTest     string  // type of statistical test ("t" or "z")
+	Significance float64 // significance level at which tests fail
+}
+
 // TestTrigger pertains to checks testing the integrity of trigger data
 type TestTrigger struct {
 	TriggerType   string    // what trigger is this "reactions", "hits", "molCounts"
@@ -179,8 +225,8 @@ type TestCheckPoint struct {
 
 // ConstraintSpec encapsulates a single constraint specification.
 type ConstraintSpec struct {
-	Target int
-	Query  []int
+	Target float64
+	Query  []float64
 }
 
 // IntList is a parse time list of strings which will be converted into an
@@ -209,6 +255,9 @@ func Parse(testPath, includePath string) (*TestDescription, error) {
 	if err != nil {
 		return &test, err
 	}
+	if test.Checks, err = parseChecks(content); err != nil {
+		return &test, err
+	}
 	for _, inc := range test.Includes {
 		incFile := filepath.Join(includePath, inc+".toml")
 		t, err := Parse(incFile, includePath)
@@ -220,6 +269,32 @@ func Parse(testPath, includePath string) (*TestDescription, error) {
 	return &test, nil
 }
 
+// parseChecks parses the common options of all checks in the test
+// description content and keeps the remaining options for decoding via
+// DecodeOptions
+func parseChecks(content []byte) ([]*TestCase, error) {
+	var raw struct {
+		Checks []toml.Primitive
+	}
+	meta, err := toml.Decode(string(content), &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []*TestCase
+	for _, p := range raw.Checks {
+		c := &TestCase{options: p, meta: &meta}
+		if err := meta.PrimitiveDecode(p, &c.TestCommon); err != nil {
+			return nil, err
+		}
+		if err := meta.PrimitiveDecode(p, &c.RawOptions); err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
 // ReadConfig reads the Configuration file
 // NOTE: For now the name of the Config file is assumed to be nutmeg.conf
 // and is expected to be located in the same directory where the nutmeg